@@ -0,0 +1,28 @@
+package chiauth
+
+import (
+	"net/http"
+
+	"github.com/c0dev0yager/goauth"
+)
+
+// Authenticate wraps goauth.AuthenticateMiddleware for chi's Use and With.
+// chi keeps request state in the request context, read it with goauth.GetID,
+// goauth.GetRole and goauth.GetLogger.
+func Authenticate(
+	roles string,
+	topicName string,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return goauth.AuthenticateMiddleware(next.ServeHTTP, roles, topicName)
+	}
+}
+
+// Unauthenticate wraps goauth.UnauthenticateMiddleware for chi's Use and With.
+func Unauthenticate(
+	topicName string,
+) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return goauth.UnauthenticateMiddleware(next.ServeHTTP, topicName)
+	}
+}
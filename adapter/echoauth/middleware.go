@@ -0,0 +1,58 @@
+package echoauth
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/c0dev0yager/goauth"
+)
+
+// TokenKey is the echo context key holding the authenticated goauth.TokenValue.
+const TokenKey = "goauth.token"
+
+// Authenticate is the echo counterpart of goauth.AuthenticateMiddleware. The
+// request context is populated the same way, so goauth.GetID, goauth.GetRole
+// and goauth.GetLogger work on c.Request().Context().
+func Authenticate(
+	roles string,
+	topicName string,
+) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			ctx := goauth.WithRequestLogger(goauth.WithRequestMeta(r), topicName)
+			ctx, tokenValue, err := goauth.GetClient().AuthenticateContext(
+				ctx,
+				goauth.GetAccessToken(r.Header.Get(echo.HeaderAuthorization)),
+				roles,
+			)
+			if err != nil {
+				goauth.SetRetryAfter(c.Response().Header(), err)
+				return c.JSON(goauth.StatusCode(err), err.Error())
+			}
+			c.SetRequest(r.WithContext(ctx))
+			c.Set(TokenKey, *tokenValue)
+			return next(c)
+		}
+	}
+}
+
+// Unauthenticate is the echo counterpart of goauth.UnauthenticateMiddleware.
+func Unauthenticate(
+	topicName string,
+) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			ctx := goauth.WithRequestLogger(goauth.WithRequestMeta(r), topicName)
+			c.SetRequest(r.WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+func GetToken(
+	c echo.Context,
+) (goauth.TokenValue, bool) {
+	tokenValue, ok := c.Get(TokenKey).(goauth.TokenValue)
+	return tokenValue, ok
+}
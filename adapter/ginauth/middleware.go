@@ -0,0 +1,57 @@
+package ginauth
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/c0dev0yager/goauth"
+)
+
+// TokenKey is the gin context key holding the authenticated goauth.TokenValue.
+const TokenKey = "goauth.token"
+
+// Authenticate is the gin counterpart of goauth.AuthenticateMiddleware. The
+// request context is populated the same way, so goauth.GetID, goauth.GetRole
+// and goauth.GetLogger work on c.Request.Context().
+func Authenticate(
+	roles string,
+	topicName string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := goauth.WithRequestLogger(goauth.WithRequestMeta(c.Request), topicName)
+		ctx, tokenValue, err := goauth.GetClient().AuthenticateContext(
+			ctx,
+			goauth.GetAccessToken(c.GetHeader("Authorization")),
+			roles,
+		)
+		if err != nil {
+			goauth.SetRetryAfter(c.Writer.Header(), err)
+			c.AbortWithStatusJSON(goauth.StatusCode(err), err.Error())
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(TokenKey, *tokenValue)
+		c.Next()
+	}
+}
+
+// Unauthenticate is the gin counterpart of goauth.UnauthenticateMiddleware.
+func Unauthenticate(
+	topicName string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := goauth.WithRequestLogger(goauth.WithRequestMeta(c.Request), topicName)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func GetToken(
+	c *gin.Context,
+) (goauth.TokenValue, bool) {
+	val, found := c.Get(TokenKey)
+	if !found {
+		return goauth.TokenValue{}, false
+	}
+	tokenValue, ok := val.(goauth.TokenValue)
+	return tokenValue, ok
+}
@@ -0,0 +1,178 @@
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/c0dev0yager/goauth"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// Public marks a method in MethodRoles as callable without a token.
+const Public = "-"
+
+// MethodRoles maps full method names ("/package.Service/Method") to dot
+// separated roles. "/package.Service/*" covers every method of a service and
+// "*" every method. Methods without an entry are denied.
+type MethodRoles map[string]string
+
+func (m MethodRoles) roles(
+	fullMethod string,
+) (string, bool) {
+	if roles, found := m[fullMethod]; found {
+		return roles, true
+	}
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		if roles, found := m[fullMethod[:i+1]+"*"]; found {
+			return roles, true
+		}
+	}
+	roles, found := m["*"]
+	return roles, found
+}
+
+func UnaryServerInterceptor(
+	methodRoles MethodRoles,
+) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, err := authenticate(ctx, methodRoles, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamServerInterceptor(
+	methodRoles MethodRoles,
+) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := authenticate(stream.Context(), methodRoles, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(
+	ctx context.Context,
+	methodRoles MethodRoles,
+	fullMethod string,
+) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = goauth.WithRequestHeader(ctx, requestHeader(ctx, md))
+	ctx = goauth.WithRequestLogger(ctx, fullMethod)
+
+	roles, found := methodRoles.roles(fullMethod)
+	if !found {
+		return nil, status.Error(codes.PermissionDenied, pkg.ErrAuthRoleMismatch.Error())
+	}
+	if roles == Public {
+		return ctx, nil
+	}
+
+	accessToken := ""
+	if values := md.Get("authorization"); len(values) > 0 {
+		accessToken = goauth.GetAccessToken(values[0])
+	}
+	ctx, _, err := goauth.GetClient().AuthenticateContext(ctx, accessToken, roles)
+	if err != nil {
+		return nil, status.Error(toCode(err), err.Error())
+	}
+	return ctx, nil
+}
+
+// requestHeader reads the same headers as goauth.WithRequestMeta from the
+// incoming metadata, the IP is taken from the peer address.
+func requestHeader(
+	ctx context.Context,
+	md metadata.MD,
+) goauth.RequestHeaderDTO {
+	get := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	dto := goauth.RequestHeaderDTO{
+		TrackingID:  get("x-tracking-id"),
+		RequestTime: get("x-request-time"),
+		Version:     get("x-version"),
+		DeviceID:    get("x-device-id"),
+		AuthID:      get("x-auth-id"),
+		UserAgent:   get("user-agent"),
+	}
+	if dto.TrackingID == "" {
+		dto.TrackingID = uuid.New().String()
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err == nil && net.ParseIP(host) != nil {
+			dto.IPv4 = host
+		}
+	}
+	return dto
+}
+
+// CheckStepUp is goauth.CheckStepUp for gRPC handlers. It sends the RFC 9470
+// challenge of stepUp in the www-authenticate header of rejected calls.
+func CheckStepUp(
+	ctx context.Context,
+	stepUp goauth.StepUp,
+) error {
+	err := goauth.CheckStepUp(ctx, stepUp)
+	if err == nil {
+		return nil
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs("www-authenticate", stepUp.Challenge()))
+	return status.Error(toCode(err), err.Error())
+}
+
+func toCode(
+	err error,
+) codes.Code {
+	if errors.Is(err, pkg.ErrAuthRoleMismatch) || errors.Is(err, pkg.ErrAuthSubjectMismatch) ||
+		errors.Is(err, pkg.ErrAuthScopeMismatch) {
+		return codes.PermissionDenied
+	}
+	if errors.Is(err, pkg.ErrAuthTokenExpired) || errors.Is(err, pkg.ErrAuthTokenInvalid) || errors.Is(
+		err, pkg.ErrAuthTokenMalformed,
+	) || errors.Is(err, pkg.ErrAuthSessionIdle) || errors.Is(err, pkg.ErrFieldValidation) || errors.Is(
+		err, pkg.ErrStepUpRequired,
+	) {
+		return codes.Unauthenticated
+	}
+	if errors.Is(err, pkg.ErrRateLimited) {
+		return codes.ResourceExhausted
+	}
+	return codes.Internal
+}
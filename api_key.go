@@ -0,0 +1,175 @@
+package goauth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+const defaultAPIKeyPrefix = "gak"
+
+type APIKeyValue struct {
+	AuthID string `json:"auth_id" validate:"required,max=100,special_character_validation"`
+	Name   string `json:"name" validate:"required,max=100"`
+	Role   string `json:"role" validate:"required,max=20,special_character_validation"`
+	// Scopes restrict the key to the endpoints wrapped by RequireScopes, an
+	// empty list grants everything the role allows
+	Scopes []string `json:"scopes,omitempty" validate:"dive,required,max=50"`
+	// ValidityInDays of zero never expires
+	ValidityInDays int `json:"validity_in_days,omitempty" validate:"min=0"`
+}
+
+// APIKeyInfo describes a stored key, the key itself is only returned once by
+// CreateAPIKey.
+type APIKeyInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	Last4     string    `json:"last4"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+type APIKeyResponse struct {
+	Key string `json:"key"`
+	APIKeyInfo
+}
+
+func toAPIKeyInfo(
+	dto domain.APIKeyDTO,
+) APIKeyInfo {
+	return APIKeyInfo{
+		ID:        string(dto.ID),
+		Name:      dto.Name,
+		Role:      dto.Role,
+		Scopes:    dto.Scopes,
+		Last4:     dto.Last4,
+		CreatedAt: dto.CreatedAt,
+		ExpiresAt: dto.ExpiresAt,
+	}
+}
+
+// CreateAPIKey issues a long-lived key accepted by Authenticate in place of an
+// access token.
+func (cl *authClient) CreateAPIKey(
+	ctx context.Context,
+	dto APIKeyValue,
+) (*APIKeyResponse, error) {
+	err := pkg.Validate.Struct(dto)
+	if err != nil {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": CreateAPIKey Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	apiKeyDTO := domain.APIKeyDTO{
+		AuthID: domain.AuthID(dto.AuthID),
+		Name:   dto.Name,
+		Role:   dto.Role,
+		Scopes: dto.Scopes,
+	}
+	if dto.ValidityInDays > 0 {
+		apiKeyDTO.ExpiresAt = time.Now().UTC().AddDate(0, 0, dto.ValidityInDays)
+	}
+	created, key, err := cl.ts.CreateAPIKey(ctx, apiKeyDTO)
+	if err != nil {
+		return nil, err
+	}
+	return &APIKeyResponse{
+		Key:        key,
+		APIKeyInfo: toAPIKeyInfo(*created),
+	}, nil
+}
+
+func (cl *authClient) ListAPIKeys(
+	ctx context.Context,
+	authID string,
+) ([]APIKeyInfo, error) {
+	if authID == "" {
+		return nil, pkg.ErrFieldValidation
+	}
+	dtos, err := cl.ts.FindAPIKeys(ctx, domain.AuthID(authID))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]APIKeyInfo, len(dtos))
+	for index, dto := range dtos {
+		keys[index] = toAPIKeyInfo(dto)
+	}
+	slices.SortFunc(keys, func(a, b APIKeyInfo) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return keys, nil
+}
+
+func (cl *authClient) RevokeAPIKey(
+	ctx context.Context,
+	authID string,
+	keyID string,
+) error {
+	if authID == "" || keyID == "" {
+		return pkg.ErrFieldValidation
+	}
+	return cl.ts.RevokeAPIKey(ctx, domain.AuthID(authID), domain.APIKeyID(keyID))
+}
+
+// validateCredential accepts both access tokens and API keys, scopes are nil
+// for unrestricted credentials.
+func (cl *authClient) validateCredential(
+	ctx context.Context,
+	credential string,
+	activity domain.SessionActivity,
+) (*domain.TokenDTO, []string, error) {
+	if cl.ts.IsAPIKey(credential) {
+		apiKey, err := cl.ts.ValidateAPIKey(ctx, credential)
+		if err != nil {
+			return nil, nil, err
+		}
+		at := apiKey.ToTokenDTO()
+		return &at, apiKey.Scopes, nil
+	}
+	at, err := cl.ts.Validate(ctx, credential, activity)
+	if err != nil {
+		return nil, nil, err
+	}
+	return at, at.Scopes, nil
+}
+
+func withScopes(
+	ctx context.Context,
+	scopes []string,
+) context.Context {
+	if len(scopes) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, ScopesKey, scopes)
+}
+
+// GetScopes returns the scopes of the authenticating API key or exchanged
+// token, nil when the request is not restricted to scopes.
+func GetScopes(
+	ctx context.Context,
+) []string {
+	scopes, _ := ctx.Value(ScopesKey).([]string)
+	return scopes
+}
+
+// HasScopes reports whether the request may use every scope. Access tokens and
+// API keys created without scopes are unrestricted.
+func HasScopes(
+	ctx context.Context,
+	scopes ...string,
+) bool {
+	granted := GetScopes(ctx)
+	if granted == nil {
+		return true
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventTokenCreated     EventType = "token_created"
+	EventTokenRefreshed   EventType = "token_refreshed"
+	EventValidationFailed EventType = "validation_failed"
+	EventTokenInvalidated EventType = "token_invalidated"
+	EventSessionRevoked   EventType = "session_revoked"
+	EventTokenExchanged   EventType = "token_exchanged"
+	EventSessionElevated  EventType = "session_elevated"
+)
+
+// Event describes a token lifecycle change. AuthID comes from the token, the
+// X-Auth-Id header sent by the client is only kept as ClaimedAuthID.
+type Event struct {
+	Type          EventType `json:"type"`
+	AuthID        string    `json:"auth_id,omitempty"`
+	ClaimedAuthID string    `json:"claimed_auth_id,omitempty"`
+	Role          string    `json:"role,omitempty"`
+	UniqueKey     string    `json:"unique_key,omitempty"`
+	TokenID       string    `json:"token_id,omitempty"`
+	ActorID       string    `json:"actor_id,omitempty"`
+	Reason        string    `json:"reason,omitempty"`
+	IP            string    `json:"ip,omitempty"`
+	DeviceID      string    `json:"device_id,omitempty"`
+	TrackingID    string    `json:"tracking_id,omitempty"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// Sink receives token lifecycle events. Emit is called synchronously on the
+// request path, so implementations should not block for long.
+type Sink interface {
+	Emit(
+		ctx context.Context,
+		event Event,
+	) error
+}
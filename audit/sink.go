@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var ErrChannelFull = errors.New("AuditChannelFull")
+
+type RedisStreamSink struct {
+	redisClient *redis.Client
+	stream      string
+	maxLen      int64
+}
+
+// NewRedisStreamSink appends events to a Redis stream, trimmed to roughly
+// maxLen entries when maxLen is positive.
+func NewRedisStreamSink(
+	redisClient *redis.Client,
+	stream string,
+	maxLen int64,
+) *RedisStreamSink {
+	return &RedisStreamSink{
+		redisClient: redisClient,
+		stream:      stream,
+		maxLen:      maxLen,
+	}
+}
+
+func (s *RedisStreamSink) Emit(
+	ctx context.Context,
+	event Event,
+) error {
+	args := &redis.XAddArgs{
+		Stream: s.stream,
+		Values: map[string]interface{}{
+			"type":        string(event.Type),
+			"auth_id":     event.AuthID,
+			"role":        event.Role,
+			"unique_key":  event.UniqueKey,
+			"token_id":    event.TokenID,
+			"actor_id":    event.ActorID,
+			"reason":      event.Reason,
+			"ip":          event.IP,
+			"device_id":   event.DeviceID,
+			"tracking_id": event.TrackingID,
+			"occurred_at": event.OccurredAt.Format(time.RFC3339Nano),
+		},
+	}
+	if s.maxLen > 0 {
+		args.MaxLen = s.maxLen
+		args.Approx = true
+	}
+	return s.redisClient.XAdd(ctx, args).Err()
+}
+
+type JSONLinesSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewJSONLinesSink(
+	writer io.Writer,
+) *JSONLinesSink {
+	return &JSONLinesSink{
+		writer: writer,
+	}
+}
+
+// NewJSONLinesFileSink appends events to the file at path, creating it if needed.
+func NewJSONLinesFileSink(
+	path string,
+) (*JSONLinesSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesSink(file), nil
+}
+
+func (s *JSONLinesSink) Emit(
+	_ context.Context,
+	event Event,
+) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.writer.Write(line)
+	return err
+}
+
+func (s *JSONLinesSink) Close() error {
+	closer, ok := s.writer.(io.Closer)
+	if !ok {
+		return nil
+	}
+	return closer.Close()
+}
+
+type ChannelSink struct {
+	events chan<- Event
+}
+
+// NewChannelSink publishes events on events without blocking, events are
+// dropped with ErrChannelFull when the consumer falls behind.
+func NewChannelSink(
+	events chan<- Event,
+) *ChannelSink {
+	return &ChannelSink{
+		events: events,
+	}
+}
+
+func (s *ChannelSink) Emit(
+	_ context.Context,
+	event Event,
+) error {
+	select {
+	case s.events <- event:
+		return nil
+	default:
+		return ErrChannelFull
+	}
+}
@@ -0,0 +1,50 @@
+package goauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dev0yager/goauth/audit"
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+// requestAuditSink enriches events with the request metadata collected by
+// requestMetaMiddleware before handing them to the configured sink.
+type requestAuditSink struct {
+	sink audit.Sink
+}
+
+func (s *requestAuditSink) Emit(
+	ctx context.Context,
+	event audit.Event,
+) error {
+	headerDTO := GetHeaderDTO(ctx)
+	event.IP = headerDTO.IPv4
+	event.DeviceID = headerDTO.DeviceID
+	event.TrackingID = headerDTO.TrackingID
+	event.ClaimedAuthID = headerDTO.AuthID
+	return s.sink.Emit(ctx, event)
+}
+
+func (cl *authClient) emitValidationFailed(
+	ctx context.Context,
+	at *domain.TokenDTO,
+	reason error,
+) {
+	if cl.audit == nil {
+		return
+	}
+	event := audit.Event{
+		Type:       audit.EventValidationFailed,
+		AuthID:     string(at.AuthID),
+		Role:       at.Role,
+		UniqueKey:  at.UniqueKey,
+		TokenID:    string(at.ID),
+		Reason:     reason.Error(),
+		OccurredAt: time.Now().UTC(),
+	}
+	err := cl.audit.Emit(ctx, event)
+	if err != nil {
+		domain.LoggerFromContext(ctx).Error(domain.LogKeyword+": Audit", "event", event.Type, "error", err)
+	}
+}
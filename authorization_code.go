@@ -0,0 +1,296 @@
+package goauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+type AppClientValue struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// GrantTypes defaults to the authorization code grant, which requires RedirectURIs
+	GrantTypes   []string `json:"grant_types,omitempty" validate:"dive,oneof=authorization_code urn:ietf:params:oauth:grant-type:device_code"`
+	RedirectURIs []string `json:"redirect_uris,omitempty" validate:"dive,required,url"`
+	// Scopes the client may request, tokens are restricted to the requested
+	// scopes or to all of these when it requests none. Without Scopes only
+	// openid can be requested.
+	Scopes []string `json:"scopes,omitempty" validate:"dive,required,max=50"`
+	// Public clients, such as mobile and single page apps, get no secret
+	Public bool `json:"public"`
+}
+
+// RegisterAppClient registers a third-party application for the
+// authorization code or device grants, ClientSecret is empty for public clients.
+func (cl *authClient) RegisterAppClient(
+	ctx context.Context,
+	dto AppClientValue,
+) (*ClientCredentials, error) {
+	if len(dto.GrantTypes) == 0 {
+		dto.GrantTypes = []string{GrantTypeAuthorizationCode}
+	}
+	err := pkg.Validate.Struct(dto)
+	if err != nil {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": RegisterAppClient Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	if slices.Contains(dto.GrantTypes, GrantTypeAuthorizationCode) && len(dto.RedirectURIs) == 0 {
+		return nil, pkg.ErrFieldValidation
+	}
+	client, secret, err := cl.ts.RegisterClient(ctx, domain.ClientDTO{
+		Name:         dto.Name,
+		GrantTypes:   dto.GrantTypes,
+		RedirectURIs: dto.RedirectURIs,
+		Scopes:       dto.Scopes,
+		Public:       dto.Public,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ClientCredentials{
+		ClientID:     string(client.ID),
+		ClientSecret: secret,
+	}, nil
+}
+
+// AuthorizeRequest is the validated authorization request passed to the
+// callbacks of AuthorizeHandler.
+type AuthorizeRequest struct {
+	ClientID    string
+	ClientName  string
+	RedirectURI string
+	Scope       string
+	State       string
+	Nonce       string
+}
+
+type ConsentDecision int
+
+const (
+	// ConsentPending means the callback wrote its own response, such as a
+	// consent page posting back to the authorize endpoint
+	ConsentPending ConsentDecision = iota
+	ConsentGranted
+	ConsentDenied
+)
+
+// AuthorizeCallbacks delegate the user interaction of the authorize endpoint
+// to the application.
+type AuthorizeCallbacks struct {
+	// Authenticate returns the signed in user the code is issued for. It
+	// returns nil once it wrote its own response, typically a redirect to the
+	// login page coming back to the same authorize URL. An empty UniqueKey
+	// gives every client its own session.
+	Authenticate func(w http.ResponseWriter, r *http.Request, req AuthorizeRequest) *TokenValue
+	// Consent asks the user to allow the client, nil grants every request
+	Consent func(w http.ResponseWriter, r *http.Request, req AuthorizeRequest, user TokenValue) ConsentDecision
+}
+
+// AuthorizeHandler serves the OAuth2 authorization endpoint (RFC 6749 section
+// 3.1) for the code response type, PKCE with S256 is required. Codes are
+// exchanged at TokenHandler.
+func (cl *authClient) AuthorizeHandler(
+	callbacks AuthorizeCallbacks,
+) http.HandlerFunc {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		err := r.ParseForm()
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed request")
+			return
+		}
+		ctx := r.Context()
+		req := AuthorizeRequest{
+			ClientID:    r.Form.Get("client_id"),
+			RedirectURI: r.Form.Get("redirect_uri"),
+			Scope:       r.Form.Get("scope"),
+			State:       r.Form.Get("state"),
+			Nonce:       r.Form.Get("nonce"),
+		}
+		// errors before the redirect URI is trusted must not redirect
+		client, err := cl.ts.GetClient(ctx, domain.ClientID(req.ClientID))
+		if err != nil {
+			if errors.Is(err, pkg.ErrClientInvalid) {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_request", "unknown client_id")
+				return
+			}
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": Authorize", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		if !client.AllowsRedirectURI(req.RedirectURI) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "redirect_uri is not registered")
+			return
+		}
+		req.ClientName = client.Name
+
+		if r.Form.Get("response_type") != "code" {
+			redirectAuthorizeError(w, r, req, "unsupported_response_type", "")
+			return
+		}
+		if !client.AllowsGrant(GrantTypeAuthorizationCode) {
+			redirectAuthorizeError(w, r, req, "unauthorized_client", "")
+			return
+		}
+		codeChallenge := r.Form.Get("code_challenge")
+		if codeChallenge == "" || r.Form.Get("code_challenge_method") != domain.CodeChallengeMethodS256 {
+			redirectAuthorizeError(w, r, req, "invalid_request", "code_challenge with method S256 is required")
+			return
+		}
+		// refused before the user signs in, the code could not be exchanged
+		if domain.IsOpenID(req.Scope) && !cl.ts.IDTokenEnabled() {
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": Authorize", "error", pkg.ErrIDTokenKeyMissing)
+			redirectAuthorizeError(w, r, req, "invalid_scope", "openid is not supported")
+			return
+		}
+		scopes, allowed := client.GrantedScopes(strings.Fields(req.Scope))
+		if !allowed {
+			redirectAuthorizeError(w, r, req, "invalid_scope", "")
+			return
+		}
+		// the callbacks see the scopes the token will be restricted to
+		req.Scope = strings.Join(scopes, " ")
+
+		user := callbacks.Authenticate(w, r, req)
+		if user == nil {
+			return
+		}
+		if user.UniqueKey == "" {
+			user.UniqueKey = "ac-" + req.ClientID
+		}
+		err = pkg.Validate.Struct(user)
+		if err != nil {
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": Authorize Validation", "error", err)
+			redirectAuthorizeError(w, r, req, "server_error", "")
+			return
+		}
+		decision := ConsentGranted
+		if callbacks.Consent != nil {
+			decision = callbacks.Consent(w, r, req, *user)
+		}
+		switch decision {
+		case ConsentPending:
+			return
+		case ConsentDenied:
+			redirectAuthorizeError(w, r, req, "access_denied", "")
+			return
+		}
+
+		token := user.ToInternalToken()
+		token.Scopes = scopes
+		code, err := cl.ts.CreateAuthorizationCode(ctx, domain.AuthorizationCodeDTO{
+			ClientID:            client.ID,
+			RedirectURI:         req.RedirectURI,
+			Scope:               req.Scope,
+			Nonce:               req.Nonce,
+			CodeChallenge:       codeChallenge,
+			CodeChallengeMethod: domain.CodeChallengeMethodS256,
+			Token:               token,
+			AuthTime:            time.Now().UTC(),
+		})
+		if err != nil {
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": Authorize", "error", err)
+			redirectAuthorizeError(w, r, req, "server_error", "")
+			return
+		}
+		redirectAuthorize(w, r, req, url.Values{"code": {code}})
+	}
+}
+
+func (cl *authClient) authorizationCodeGrant(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	client := cl.grantClient(w, r, GrantTypeAuthorizationCode)
+	if client == nil {
+		return
+	}
+
+	tokenResponse, code, err := cl.ts.ExchangeAuthorizationCode(
+		ctx,
+		r.PostForm.Get("code"),
+		*client,
+		r.PostForm.Get("redirect_uri"),
+		r.PostForm.Get("code_verifier"),
+	)
+	if err != nil {
+		if errors.Is(err, pkg.ErrGrantInvalid) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "")
+			return
+		}
+		pkg.GetFromContext(ctx).Error(domain.LogKeyword+": AuthorizationCodeGrant", "error", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	res := TokenResponseDTO{
+		AccessToken: pkg.JWTToken(tokenResponse.AccessToken),
+		RefreshKey:  tokenResponse.RefreshKey,
+		ExpiresAt:   tokenResponse.ExpiresAt,
+	}
+	if domain.IsOpenID(code.Scope) {
+		idToken := IDTokenValue{
+			ClientID: string(client.ID),
+			Nonce:    code.Nonce,
+			AuthTime: code.AuthTime,
+			AMR:      code.Token.AuthMethods,
+		}
+		if code.Token.AuthLevel > 0 {
+			idToken.ACR = strconv.Itoa(code.Token.AuthLevel)
+		}
+		res.IDToken, err = cl.ts.CreateIDToken(idToken.toInternal(string(code.Token.AuthID), res.AccessToken))
+		if err != nil {
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": AuthorizationCodeGrant IDToken", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+	}
+	writeOAuthToken(w, &res, code.Scope)
+}
+
+func redirectAuthorizeError(
+	w http.ResponseWriter,
+	r *http.Request,
+	req AuthorizeRequest,
+	code string,
+	description string,
+) {
+	params := url.Values{"error": {code}}
+	if description != "" {
+		params.Set("error_description", description)
+	}
+	redirectAuthorize(w, r, req, params)
+}
+
+// redirectAuthorize sends the user back to the client with params and state.
+func redirectAuthorize(
+	w http.ResponseWriter,
+	r *http.Request,
+	req AuthorizeRequest,
+	params url.Values,
+) {
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed redirect_uri")
+		return
+	}
+	query := target.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	target.RawQuery = query.Encode()
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
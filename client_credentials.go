@@ -0,0 +1,290 @@
+package goauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+const (
+	GrantTypeClientCredentials = domain.GrantTypeClientCredentials
+	GrantTypeAuthorizationCode = domain.GrantTypeAuthorizationCode
+	GrantTypeDeviceCode        = domain.GrantTypeDeviceCode
+	GrantTypeTokenExchange     = domain.GrantTypeTokenExchange
+
+	clientUniqueKeySize = 12
+)
+
+// ClientCredentials is returned once on registration and rotation, only the
+// secret hash is stored.
+type ClientCredentials struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+type ClientValue struct {
+	Name string `json:"name" validate:"required,max=100"`
+	Role string `json:"role" validate:"required,max=20,special_character_validation"`
+	// GrantTypes defaults to the client credentials grant
+	GrantTypes []string `json:"grant_types,omitempty" validate:"dive,oneof=client_credentials urn:ietf:params:oauth:grant-type:token-exchange"`
+}
+
+// OAuthTokenResponse is the RFC 6749 token endpoint response. It has no
+// refresh token, the token endpoint offers no refresh_token grant and clients
+// start a new grant once the access token expires.
+type OAuthTokenResponse struct {
+	AccessToken pkg.JWTToken `json:"access_token"`
+	TokenType   string       `json:"token_type"`
+	ExpiresIn   int64        `json:"expires_in"`
+	IDToken     string       `json:"id_token,omitempty"`
+	Scope       string       `json:"scope,omitempty"`
+	// IssuedTokenType is set by the token exchange grant
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+}
+
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// RegisterClient creates a service account whose tokens carry role.
+func (cl *authClient) RegisterClient(
+	ctx context.Context,
+	dto ClientValue,
+) (*ClientCredentials, error) {
+	if len(dto.GrantTypes) == 0 {
+		dto.GrantTypes = []string{GrantTypeClientCredentials}
+	}
+	err := pkg.Validate.Struct(dto)
+	if err != nil {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": RegisterClient Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	client, secret, err := cl.ts.RegisterClient(ctx, domain.ClientDTO{
+		Name:       dto.Name,
+		Role:       dto.Role,
+		GrantTypes: dto.GrantTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ClientCredentials{
+		ClientID:     string(client.ID),
+		ClientSecret: secret,
+	}, nil
+}
+
+// RotateClientSecret issues a new secret, the previous ones keep working for
+// graceInMins so callers can be redeployed without downtime.
+func (cl *authClient) RotateClientSecret(
+	ctx context.Context,
+	clientID string,
+	graceInMins int,
+) (*ClientCredentials, error) {
+	if clientID == "" || graceInMins < 0 {
+		return nil, pkg.ErrFieldValidation
+	}
+	secret, err := cl.ts.RotateClientSecret(
+		ctx, domain.ClientID(clientID), time.Duration(graceInMins)*time.Minute,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientCredentials{
+		ClientID:     clientID,
+		ClientSecret: secret,
+	}, nil
+}
+
+func (cl *authClient) DeleteClient(
+	ctx context.Context,
+	clientID string,
+) error {
+	if clientID == "" {
+		return pkg.ErrFieldValidation
+	}
+	return cl.ts.DeleteClient(ctx, domain.ClientID(clientID))
+}
+
+// ClientCredentialsToken verifies the client secret and issues a service
+// token, service tokens have no refresh key.
+func (cl *authClient) ClientCredentialsToken(
+	ctx context.Context,
+	clientID string,
+	clientSecret string,
+) (*TokenResponseDTO, error) {
+	if clientID == "" || clientSecret == "" {
+		return nil, pkg.ErrClientInvalid
+	}
+	client, err := cl.ts.VerifyClient(ctx, domain.ClientID(clientID), clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(GrantTypeClientCredentials) {
+		return nil, pkg.ErrClientInvalid
+	}
+	// every grant is its own session so replicas do not replace each other
+	uniqueKey, err := domain.RandomString(clientUniqueKeySize)
+	if err != nil {
+		return nil, err
+	}
+	tokenValue := TokenValue{
+		AuthID:    string(client.ID),
+		Role:      client.Role,
+		UniqueKey: "cc-" + uniqueKey,
+	}
+	accessTokenDTO := tokenValue.ToInternalToken()
+	accessTokenDTO.Subject = domain.SubjectService
+
+	tokenResponse, err := cl.ts.Create(ctx, accessTokenDTO)
+	if err != nil {
+		return nil, err
+	}
+	res := TokenResponseDTO{
+		AccessToken: pkg.JWTToken(tokenResponse.AccessToken),
+		ExpiresAt:   tokenResponse.ExpiresAt,
+	}
+	return &res, nil
+}
+
+// TokenHandler serves an OAuth2 token endpoint (RFC 6749 section 3.2). Client
+// credentials are read from HTTP Basic auth or the client_id and client_secret
+// form fields, public clients only send client_id.
+func (cl *authClient) TokenHandler() http.HandlerFunc {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		if r.Method != http.MethodPost {
+			writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "method must be POST")
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+			return
+		}
+
+		grantType := r.PostForm.Get("grant_type")
+		switch grantType {
+		case GrantTypeClientCredentials:
+			cl.clientCredentialsGrant(w, r)
+		case GrantTypeAuthorizationCode:
+			cl.authorizationCodeGrant(w, r)
+		case GrantTypeDeviceCode:
+			cl.deviceCodeGrant(w, r)
+		case GrantTypeTokenExchange:
+			cl.tokenExchangeGrant(w, r)
+		case "":
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		default:
+			writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		}
+	}
+}
+
+func (cl *authClient) clientCredentialsGrant(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	clientID, clientSecret := clientAuthentication(r)
+	res, err := cl.ClientCredentialsToken(r.Context(), clientID, clientSecret)
+	if err != nil {
+		if errors.Is(err, pkg.ErrClientInvalid) {
+			writeInvalidClient(w)
+			return
+		}
+		pkg.GetFromContext(r.Context()).Error(domain.LogKeyword+": ClientCredentialsGrant", "error", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	writeOAuthToken(w, res, "")
+}
+
+// clientAuthentication reads the client credentials from HTTP Basic auth or
+// the client_id and client_secret form fields.
+func clientAuthentication(
+	r *http.Request,
+) (string, string) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	return clientID, clientSecret
+}
+
+// grantClient authenticates the client of a token request and checks it may
+// use grantType, it writes the error response and returns nil otherwise.
+func (cl *authClient) grantClient(
+	w http.ResponseWriter,
+	r *http.Request,
+	grantType string,
+) *domain.ClientDTO {
+	ctx := r.Context()
+	clientID, clientSecret := clientAuthentication(r)
+	client, err := cl.ts.VerifyClient(ctx, domain.ClientID(clientID), clientSecret)
+	if err != nil {
+		if errors.Is(err, pkg.ErrClientInvalid) {
+			writeInvalidClient(w)
+			return nil
+		}
+		pkg.GetFromContext(ctx).Error(domain.LogKeyword+": TokenRequest", "grant_type", grantType, "error", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return nil
+	}
+	if !client.AllowsGrant(grantType) {
+		writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "")
+		return nil
+	}
+	return client
+}
+
+func writeInvalidClient(
+	w http.ResponseWriter,
+) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="goauth"`)
+	writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "")
+}
+
+func writeOAuthToken(
+	w http.ResponseWriter,
+	res *TokenResponseDTO,
+	scope string,
+) {
+	writeJSON(w, "no-store", newOAuthTokenResponse(res, scope))
+}
+
+func newOAuthTokenResponse(
+	res *TokenResponseDTO,
+	scope string,
+) OAuthTokenResponse {
+	expiresIn := time.Until(time.UnixMilli(res.ExpiresAt))
+	return OAuthTokenResponse{
+		AccessToken: res.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(expiresIn.Seconds()),
+		IDToken:     res.IDToken,
+		Scope:       scope,
+	}
+}
+
+func writeOAuthError(
+	w http.ResponseWriter,
+	status int,
+	code string,
+	description string,
+) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(oauthError{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
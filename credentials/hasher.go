@@ -0,0 +1,198 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrHashMalformed        = errors.New("PasswordHashMalformed")
+	ErrAlgorithmUnsupported = errors.New("PasswordAlgorithmUnsupported")
+)
+
+type Algorithm string
+
+const (
+	AlgorithmArgon2id Algorithm = "argon2id"
+	AlgorithmBcrypt   Algorithm = "bcrypt"
+)
+
+// Argon2idParams are encoded in every hash, so they can be raised without
+// breaking stored passwords. Memory is in KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams follow the second recommended option of RFC 9106.
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// valid reports whether argon2.IDKey can derive a key with p.
+func (p Argon2idParams) valid() bool {
+	return p.Memory > 0 && p.Iterations > 0 && p.Parallelism > 0 && p.SaltLength > 0 && p.KeyLength > 0
+}
+
+var b64 = base64.RawStdEncoding
+
+// Hasher hashes new passwords with Algorithm and verifies hashes of every
+// supported algorithm, reporting those to rehash with the current settings.
+type Hasher struct {
+	Algorithm  Algorithm
+	Argon2id   Argon2idParams
+	BcryptCost int
+
+	dummyOnce sync.Once
+	dummy     string
+}
+
+// NewHasher returns a Hasher using algorithm with the default parameters.
+func NewHasher(
+	algorithm Algorithm,
+) *Hasher {
+	return &Hasher{
+		Algorithm:  algorithm,
+		Argon2id:   DefaultArgon2idParams,
+		BcryptCost: bcrypt.DefaultCost,
+	}
+}
+
+// Hash returns the encoded hash of password, a PHC string for Argon2id and
+// the modular crypt format for bcrypt. Parameters that could not be verified,
+// such as those of a zero Hasher, fail with ErrHashMalformed.
+func (h *Hasher) Hash(
+	password string,
+) (string, error) {
+	switch h.Algorithm {
+	case AlgorithmArgon2id:
+		if !h.Argon2id.valid() {
+			return "", ErrHashMalformed
+		}
+		salt := make([]byte, h.Argon2id.SaltLength)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", err
+		}
+		params := h.Argon2id
+		key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		return fmt.Sprintf(
+			"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+			b64.EncodeToString(salt), b64.EncodeToString(key),
+		), nil
+	case AlgorithmBcrypt:
+		// bcrypt would silently use its default below MinCost
+		if h.BcryptCost < bcrypt.MinCost || h.BcryptCost > bcrypt.MaxCost {
+			return "", ErrHashMalformed
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	default:
+		return "", ErrAlgorithmUnsupported
+	}
+}
+
+// Verify compares password with encoded in constant time. rehash is true when
+// encoded uses another algorithm or other parameters than h, the caller
+// should then store a new Hash of the verified password.
+func (h *Hasher) Verify(
+	password string,
+	encoded string,
+) (match bool, rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, false, err
+		}
+		computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false, nil
+		}
+		return true, h.Algorithm != AlgorithmArgon2id || params != h.Argon2id, nil
+	case strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, ErrHashMalformed
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, ErrHashMalformed
+		}
+		return true, h.Algorithm != AlgorithmBcrypt || cost != h.BcryptCost, nil
+	default:
+		return false, false, ErrAlgorithmUnsupported
+	}
+}
+
+// VerifyDummy spends the time of a verification, so logins without a stored
+// hash cannot be told apart from wrong passwords by timing.
+func (h *Hasher) VerifyDummy(
+	password string,
+) {
+	h.dummyOnce.Do(func() {
+		dummy, err := h.Hash("goauth-dummy-password")
+		if err != nil {
+			// an empty dummy would return at once
+			dummy, _ = NewHasher(AlgorithmArgon2id).Hash("goauth-dummy-password")
+		}
+		h.dummy = dummy
+	})
+	_, _, _ = h.Verify(password, h.dummy)
+}
+
+func decodeArgon2id(
+	encoded string,
+) (Argon2idParams, []byte, []byte, error) {
+	params := Argon2idParams{}
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrHashMalformed
+	}
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return params, nil, nil, ErrHashMalformed
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return params, nil, nil, ErrHashMalformed
+	}
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrHashMalformed
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrHashMalformed
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	// argon2.IDKey panics on zero iterations or parallelism
+	if !params.valid() {
+		return params, nil, nil, ErrHashMalformed
+	}
+	return params, salt, key, nil
+}
@@ -0,0 +1,119 @@
+package credentials
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2idParams keep the tests fast, they are far below production use.
+var testArgon2idParams = Argon2idParams{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestHashVerify(t *testing.T) {
+	hashers := []*Hasher{
+		{Algorithm: AlgorithmArgon2id, Argon2id: testArgon2idParams},
+		{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost},
+	}
+	for _, h := range hashers {
+		encoded, err := h.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s Hash: %v", h.Algorithm, err)
+		}
+		match, rehash, err := h.Verify("correct horse", encoded)
+		if err != nil || !match || rehash {
+			t.Errorf("%s Verify = %v, %v, %v, want match without rehash", h.Algorithm, match, rehash, err)
+		}
+		match, _, err = h.Verify("wrong horse", encoded)
+		if err != nil || match {
+			t.Errorf("%s Verify of wrong password = %v, %v", h.Algorithm, match, err)
+		}
+	}
+}
+
+func TestVerifyRehash(t *testing.T) {
+	old := &Hasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost}
+	encoded, err := old.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raised := &Hasher{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MinCost + 1}
+	match, rehash, err := raised.Verify("password", encoded)
+	if err != nil || !match || !rehash {
+		t.Errorf("raised bcrypt cost = %v, %v, %v, want rehash", match, rehash, err)
+	}
+
+	argon := &Hasher{Algorithm: AlgorithmArgon2id, Argon2id: testArgon2idParams}
+	match, rehash, err = argon.Verify("password", encoded)
+	if err != nil || !match || !rehash {
+		t.Errorf("other algorithm = %v, %v, %v, want rehash", match, rehash, err)
+	}
+
+	encoded, err = argon.Hash("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon.Argon2id.Iterations++
+	match, rehash, err = argon.Verify("password", encoded)
+	if err != nil || !match || !rehash {
+		t.Errorf("raised Argon2id iterations = %v, %v, %v, want rehash", match, rehash, err)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	h := &Hasher{Algorithm: AlgorithmArgon2id, Argon2id: testArgon2idParams}
+	hashes := []string{
+		"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+		"$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+		"$argon2id$v=19$m=64,t=1,p=1$$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+		"$argon2id$v=19$m=64,t=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA",
+		"$2a$04$short",
+	}
+	for _, encoded := range hashes {
+		_, _, err := h.Verify("password", encoded)
+		if !errors.Is(err, ErrHashMalformed) {
+			t.Errorf("Verify(%q) error = %v, want ErrHashMalformed", encoded, err)
+		}
+	}
+	_, _, err := h.Verify("password", "$md5$abc")
+	if !errors.Is(err, ErrAlgorithmUnsupported) {
+		t.Errorf("Verify of md5 error = %v, want ErrAlgorithmUnsupported", err)
+	}
+}
+
+func TestHashInvalidParams(t *testing.T) {
+	hashers := []*Hasher{
+		{Algorithm: AlgorithmArgon2id},
+		{Algorithm: AlgorithmBcrypt},
+		{Algorithm: AlgorithmBcrypt, BcryptCost: bcrypt.MaxCost + 1},
+	}
+	for _, h := range hashers {
+		_, err := h.Hash("password")
+		if !errors.Is(err, ErrHashMalformed) {
+			t.Errorf("%s Hash error = %v, want ErrHashMalformed", h.Algorithm, err)
+		}
+	}
+	_, err := (&Hasher{}).Hash("password")
+	if !errors.Is(err, ErrAlgorithmUnsupported) {
+		t.Errorf("Hash without Algorithm error = %v, want ErrAlgorithmUnsupported", err)
+	}
+}
+
+func TestVerifyDummyInvalidParams(t *testing.T) {
+	h := &Hasher{Algorithm: AlgorithmArgon2id}
+	h.VerifyDummy("password")
+	if h.dummy == "" {
+		t.Fatal("VerifyDummy left no dummy hash to verify")
+	}
+}
@@ -0,0 +1,31 @@
+package credentials
+
+import (
+	"context"
+)
+
+// Credential is the stored password of a login, PasswordHash is produced by
+// Hasher.Hash.
+type Credential struct {
+	AuthID       string
+	Role         string
+	PasswordHash string
+}
+
+// CredentialStore is implemented by the application on top of its user
+// storage.
+type CredentialStore interface {
+	// FindCredential returns nil without error when login is unknown
+	FindCredential(
+		ctx context.Context,
+		login string,
+	) (*Credential, error)
+
+	// UpdatePasswordHash stores the rehashed password of authID after a
+	// successful login with outdated hashing parameters
+	UpdatePasswordHash(
+		ctx context.Context,
+		authID string,
+		passwordHash string,
+	) error
+}
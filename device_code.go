@@ -0,0 +1,246 @@
+package goauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// deviceIDSize is the random part of device IDs generated for devices that
+// do not send one.
+const deviceIDSize = 12
+
+// DeviceAuthorizationResponse is the RFC 8628 section 3.2 response.
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceAuthorization describes a pending device authorization to the user
+// asked to approve it.
+type DeviceAuthorization struct {
+	ClientID   string
+	ClientName string
+	Scope      string
+	DeviceID   string
+	ExpiresAt  time.Time
+}
+
+// DeviceAuthorizationHandler serves the device authorization endpoint of RFC
+// 8628. verificationURI is the page where users enter the user code, the
+// optional device_id form field becomes the UniqueKey of the session behind a
+// "dev-" prefix, so devices cannot replace sessions of other unique keys.
+func (cl *authClient) DeviceAuthorizationHandler(
+	verificationURI string,
+) http.HandlerFunc {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		if r.Method != http.MethodPost {
+			writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "method must be POST")
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+			return
+		}
+		ctx := r.Context()
+		client := cl.grantClient(w, r, GrantTypeDeviceCode)
+		if client == nil {
+			return
+		}
+		deviceID := r.PostForm.Get("device_id")
+		if deviceID == "" {
+			deviceID, err = domain.RandomString(deviceIDSize)
+			if err != nil {
+				pkg.GetFromContext(ctx).Error(domain.LogKeyword+": DeviceAuthorization", "error", err)
+				writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+				return
+			}
+		}
+		deviceID = "dev-" + deviceID
+		err = pkg.Validate.Var(deviceID, "max=100,special_character_validation")
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed device_id")
+			return
+		}
+
+		scopes, allowed := client.GrantedScopes(strings.Fields(r.PostForm.Get("scope")))
+		if !allowed {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "")
+			return
+		}
+
+		deviceCode, dto, err := cl.ts.CreateDeviceCode(ctx, domain.DeviceCodeDTO{
+			ClientID: client.ID,
+			Scope:    strings.Join(scopes, " "),
+			DeviceID: deviceID,
+		})
+		if err != nil {
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": DeviceAuthorization", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		complete, err := url.Parse(verificationURI)
+		if err != nil {
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": DeviceAuthorization", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		query := complete.Query()
+		query.Set("user_code", dto.UserCode)
+		complete.RawQuery = query.Encode()
+
+		writeJSON(w, "no-store", DeviceAuthorizationResponse{
+			DeviceCode:              deviceCode,
+			UserCode:                dto.UserCode,
+			VerificationURI:         verificationURI,
+			VerificationURIComplete: complete.String(),
+			ExpiresIn:               int64(time.Until(dto.ExpiresAt).Seconds()),
+			Interval:                int64(dto.Interval.Seconds()),
+		})
+	}
+}
+
+// GetDeviceAuthorization returns the pending authorization of userCode, for
+// the application to show which client asks for access.
+func (cl *authClient) GetDeviceAuthorization(
+	ctx context.Context,
+	userCode string,
+) (*DeviceAuthorization, error) {
+	if userCode == "" {
+		return nil, pkg.ErrFieldValidation
+	}
+	_, dto, err := cl.ts.GetDeviceCode(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	client, err := cl.ts.GetClient(ctx, dto.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceAuthorization{
+		ClientID:   string(dto.ClientID),
+		ClientName: client.Name,
+		Scope:      dto.Scope,
+		DeviceID:   dto.DeviceID,
+		ExpiresAt:  dto.ExpiresAt,
+	}, nil
+}
+
+// ApproveDevice lets the device polling userCode sign in as user, the device
+// ID replaces the UniqueKey of user.
+func (cl *authClient) ApproveDevice(
+	ctx context.Context,
+	userCode string,
+	user TokenValue,
+) error {
+	user.UniqueKey = ""
+	err := pkg.Validate.Struct(user)
+	if err != nil || userCode == "" {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": ApproveDevice Validation", "error", err)
+		return pkg.ErrFieldValidation
+	}
+	token := user.ToInternalToken()
+	return cl.ts.DecideDeviceCode(ctx, userCode, &token)
+}
+
+func (cl *authClient) DenyDevice(
+	ctx context.Context,
+	userCode string,
+) error {
+	if userCode == "" {
+		return pkg.ErrFieldValidation
+	}
+	return cl.ts.DecideDeviceCode(ctx, userCode, nil)
+}
+
+// DeviceVerificationHandler approves, or denies when the action form field
+// is "deny", the user_code posted by a signed in user. authenticate returns
+// that user, or nil once it wrote its own response.
+func (cl *authClient) DeviceVerificationHandler(
+	authenticate func(w http.ResponseWriter, r *http.Request) *TokenValue,
+) http.HandlerFunc {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		if r.Method != http.MethodPost {
+			writeOAuthError(w, http.StatusMethodNotAllowed, "invalid_request", "method must be POST")
+			return
+		}
+		err := r.ParseForm()
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+			return
+		}
+		user := authenticate(w, r)
+		if user == nil {
+			return
+		}
+		userCode := r.PostForm.Get("user_code")
+		if r.PostForm.Get("action") == "deny" {
+			err = cl.DenyDevice(r.Context(), userCode)
+		} else {
+			err = cl.ApproveDevice(r.Context(), userCode, *user)
+		}
+		if err != nil {
+			if errors.Is(err, pkg.ErrGrantInvalid) || errors.Is(err, pkg.ErrFieldValidation) {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "unknown or expired user_code")
+				return
+			}
+			pkg.GetFromContext(r.Context()).Error(domain.LogKeyword+": DeviceVerification", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (cl *authClient) deviceCodeGrant(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	client := cl.grantClient(w, r, GrantTypeDeviceCode)
+	if client == nil {
+		return
+	}
+	tokenResponse, device, err := cl.ts.PollDeviceCode(ctx, r.PostForm.Get("device_code"), *client)
+	if err != nil {
+		switch {
+		case errors.Is(err, pkg.ErrAuthorizationPending):
+			writeOAuthError(w, http.StatusBadRequest, "authorization_pending", "")
+		case errors.Is(err, pkg.ErrSlowDown):
+			writeOAuthError(w, http.StatusBadRequest, "slow_down", "")
+		case errors.Is(err, pkg.ErrAccessDenied):
+			writeOAuthError(w, http.StatusBadRequest, "access_denied", "")
+		case errors.Is(err, pkg.ErrDeviceCodeExpired):
+			writeOAuthError(w, http.StatusBadRequest, "expired_token", "")
+		case errors.Is(err, pkg.ErrGrantInvalid):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "")
+		default:
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": DeviceCodeGrant", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+	res := TokenResponseDTO{
+		AccessToken: pkg.JWTToken(tokenResponse.AccessToken),
+		RefreshKey:  tokenResponse.RefreshKey,
+		ExpiresAt:   tokenResponse.ExpiresAt,
+	}
+	writeOAuthToken(w, &res, device.Scope)
+}
@@ -2,6 +2,7 @@ package goauth
 
 import (
 	"context"
+	"log/slog"
 	"net"
 	"net/http"
 	"strings"
@@ -16,15 +17,31 @@ type contextKey string
 const (
 	AuthIDKey               contextKey = "authId"
 	AuthRoleKey             contextKey = "authRoleKey"
+	SubjectTypeKey          contextKey = "subjectType"
+	ScopesKey               contextKey = "scopes"
+	ActorKey                contextKey = "actor"
+	AuthenticationKey       contextKey = "authentication"
 	TrackingIDContextKey    contextKey = "trackingId"
-	LoggerContextKey        contextKey = "httpLogger"
 	RequestHeaderContextKey contextKey = "requestHeader"
 )
 
+// LoggerContextKey holds the request scoped *slog.Logger, see GetLogger.
+const LoggerContextKey = domain.LoggerContextKey
+
 type TokenValue struct {
 	AuthID    string `json:"auth_id" validate:"required,max=100,special_character_validation"`
 	Role      string `json:"role" validate:"required,max=20,special_character_validation"`
 	UniqueKey string `json:"unique_key" validate:"max=100,special_character_validation"`
+	// ValidityInMins overrides the lifetime policy for this token and its refreshes
+	ValidityInMins int `json:"validity_in_mins,omitempty" validate:"min=0"`
+	// TokenFormat overrides Config.TokenFormat for this token and its refreshes
+	TokenFormat TokenFormat `json:"token_format,omitempty" validate:"omitempty,oneof=jwt opaque"`
+	// Audience lists the services the token is issued for, defaults to Config.Audience
+	Audience []string `json:"audience,omitempty" validate:"dive,required,max=100"`
+	// AuthMethods are the RFC 8176 methods the user just authenticated with,
+	// such as "pwd", and AuthLevel how strongly, see AuthLevelMultiFactor
+	AuthMethods []string `json:"auth_methods,omitempty" validate:"dive,required,max=20"`
+	AuthLevel   int      `json:"auth_level,omitempty" validate:"min=0"`
 }
 
 func (e *TokenValue) ToInternalToken() domain.TokenDTO {
@@ -33,13 +50,26 @@ func (e *TokenValue) ToInternalToken() domain.TokenDTO {
 		AuthID:    domain.AuthID(e.AuthID),
 		Role:      e.Role,
 		UniqueKey: "def",
+		Format:    e.TokenFormat,
+		Audience:  e.Audience,
+		StartedAt: ts,
 		CreatedAt: ts,
-		ExpiresAt: ts.Add(time.Duration(cl.config.JwtValidityInMins) * time.Minute),
+
+		AuthTime:    ts,
+		AuthMethods: e.AuthMethods,
+		AuthLevel:   e.AuthLevel,
 	}
 	if e.UniqueKey != "" {
 		dto.UniqueKey = e.UniqueKey
 	}
 
+	validity := cl.ts.Validity(dto.Role, dto.UniqueKey)
+	if e.ValidityInMins > 0 {
+		dto.Lifetime = time.Duration(e.ValidityInMins) * time.Minute
+		validity = dto.Lifetime
+	}
+	dto.ExpiresAt = ts.Add(validity)
+
 	return dto
 }
 
@@ -51,6 +81,9 @@ type TokenResponseDTO struct {
 	AccessToken pkg.JWTToken `json:"access_token"`
 	RefreshKey  string       `json:"refresh_key"`
 	ExpiresAt   int64        `json:"expires_at"`
+	IDToken     string       `json:"id_token,omitempty"`
+	// MFAToken replaces the tokens of a login waiting for its second factor
+	MFAToken string `json:"mfa_token,omitempty"`
 }
 
 type RequestHeaderDTO struct {
@@ -60,6 +93,7 @@ type RequestHeaderDTO struct {
 	Version     string
 	TrackingID  string
 	RequestTime string
+	UserAgent   string
 }
 
 func GetHeaderDTO(
@@ -74,6 +108,68 @@ func GetHeaderDTO(
 	return requestHeaderDTO
 }
 
+func getSessionActivity(
+	ctx context.Context,
+) domain.SessionActivity {
+	headerDTO := GetHeaderDTO(ctx)
+	return domain.SessionActivity{
+		IP:        headerDTO.IPv4,
+		UserAgent: headerDTO.UserAgent,
+		SeenAt:    time.Now().UTC(),
+	}
+}
+
+// GetLogger returns the logger carrying the request fields (topic, tracking
+// and auth IDs) added by the middlewares.
+func GetLogger(
+	ctx context.Context,
+) *slog.Logger {
+	return domain.LoggerFromContext(ctx)
+}
+
+func withAuth(
+	ctx context.Context,
+	tokenValue TokenValue,
+	subject SubjectType,
+) context.Context {
+	ctx = context.WithValue(ctx, AuthIDKey, tokenValue.AuthID)
+	ctx = context.WithValue(ctx, AuthRoleKey, tokenValue.Role)
+	ctx = context.WithValue(ctx, SubjectTypeKey, subject)
+
+	headerDTO := GetHeaderDTO(ctx)
+	headerDTO.AuthID = tokenValue.AuthID
+	ctx = context.WithValue(ctx, RequestHeaderContextKey, headerDTO)
+
+	logger := GetLogger(ctx).With("auth_id", tokenValue.AuthID)
+	return domain.WithLogger(ctx, logger)
+}
+
+// withActor adds the actor of an exchanged token, if any, to ctx and its logger.
+func withActor(
+	ctx context.Context,
+	dto *domain.ActorDTO,
+) context.Context {
+	actor := toActor(dto)
+	if actor == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, ActorKey, actor)
+	logger := GetLogger(ctx).With("actor_id", actor.AuthID)
+	return domain.WithLogger(ctx, logger)
+}
+
+// GetAccessToken extracts the token from an Authorization header value, with
+// or without the Bearer scheme.
+func GetAccessToken(
+	authorization string,
+) string {
+	scheme, token, found := strings.Cut(strings.TrimSpace(authorization), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(authorization)
+}
+
 func GetID(
 	ctx context.Context,
 ) string {
@@ -87,6 +183,22 @@ func GetRole(
 	return role
 }
 
+func GetSubjectType(
+	ctx context.Context,
+) SubjectType {
+	subject, _ := ctx.Value(SubjectTypeKey).(SubjectType)
+	return subject
+}
+
+// GetActor returns who acts on behalf of the authenticated subject, nil unless
+// the request uses a delegated token.
+func GetActor(
+	ctx context.Context,
+) *Actor {
+	actor, _ := ctx.Value(ActorKey).(*Actor)
+	return actor
+}
+
 func getIP(r *http.Request) string {
 	// Get IP from the X-REAL-IP header
 	ip := r.Header.Get("X-REAL-IP")
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dev0yager/goauth/audit"
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/metrics"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// IsAPIKey tells whether token looks like an API key rather than a JWT.
+func (s *TokenService) IsAPIKey(
+	token string,
+) bool {
+	_, ok := domain.ParseAPIKey(s.cfg.APIKeyPrefix, token)
+	return ok
+}
+
+// CreateAPIKey stores dto under a new key and returns the key, which is only
+// kept hashed and cannot be read back.
+func (s *TokenService) CreateAPIKey(
+	ctx context.Context,
+	dto domain.APIKeyDTO,
+) (*domain.APIKeyDTO, string, error) {
+	id, key, err := domain.NewAPIKey(s.cfg.APIKeyPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+	dto.ID = id
+	dto.Hash = domain.HashSecret(key, s.cfg.EncKey)
+	dto.Last4 = key[len(key)-4:]
+	dto.CreatedAt = time.Now().UTC()
+
+	err = s.rep.IAPIKey.Add(ctx, dto)
+	if err != nil {
+		return nil, "", err
+	}
+	s.emit(ctx, audit.EventTokenCreated, dto.ToTokenDTO(), nil)
+	return &dto, key, nil
+}
+
+func (s *TokenService) ValidateAPIKey(
+	ctx context.Context,
+	key string,
+) (*domain.APIKeyDTO, error) {
+	ctx, span := s.startSpan(ctx, "TokenService.ValidateAPIKey", "")
+	res, err := s.validateAPIKey(ctx, key)
+	endSpan(span, err)
+	return res, err
+}
+
+func (s *TokenService) validateAPIKey(
+	ctx context.Context,
+	key string,
+) (*domain.APIKeyDTO, error) {
+	id, ok := domain.ParseAPIKey(s.cfg.APIKeyPrefix, key)
+	if !ok {
+		s.validationFailed(ctx, domain.TokenDTO{}, metrics.ReasonMalformed, pkg.ErrAuthTokenMalformed)
+		return nil, pkg.ErrAuthTokenMalformed
+	}
+	dto, err := s.rep.IAPIKey.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dto == nil || !domain.SecretMatches(key, dto.Hash, s.cfg.EncKey) {
+		s.validationFailed(ctx, domain.TokenDTO{}, metrics.ReasonInvalid, pkg.ErrAuthTokenInvalid)
+		return nil, pkg.ErrAuthTokenInvalid
+	}
+	if dto.IsExpired(time.Now().UTC()) {
+		s.validationFailed(ctx, dto.ToTokenDTO(), metrics.ReasonExpired, pkg.ErrAuthTokenExpired)
+		return nil, pkg.ErrAuthTokenExpired
+	}
+	return dto, nil
+}
+
+func (s *TokenService) FindAPIKeys(
+	ctx context.Context,
+	authID domain.AuthID,
+) ([]domain.APIKeyDTO, error) {
+	return s.rep.IAPIKey.FindByAuthID(ctx, authID)
+}
+
+// RevokeAPIKey deletes the key id when it belongs to authID.
+func (s *TokenService) RevokeAPIKey(
+	ctx context.Context,
+	authID domain.AuthID,
+	id domain.APIKeyID,
+) error {
+	dto, err := s.rep.IAPIKey.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+	if dto == nil || dto.AuthID != authID {
+		return pkg.ErrAPIKeyNotFound
+	}
+	err = s.rep.IAPIKey.Delete(ctx, *dto)
+	if err != nil {
+		return err
+	}
+	s.metrics.TokenInvalidated(1)
+	s.emit(ctx, audit.EventTokenInvalidated, dto.ToTokenDTO(), nil)
+	return nil
+}
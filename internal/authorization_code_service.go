@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+const authorizationCodeSize = 32
+
+// CreateAuthorizationCode stores dto for CodeValidity and returns the code
+// handed to the client through its redirect URI.
+func (s *TokenService) CreateAuthorizationCode(
+	ctx context.Context,
+	dto domain.AuthorizationCodeDTO,
+) (string, error) {
+	code, err := domain.RandomString(authorizationCodeSize)
+	if err != nil {
+		return "", err
+	}
+	dto.ExpiresAt = time.Now().UTC().Add(s.cfg.CodeValidity)
+	err = s.rep.ICode.AddAuthorizationCode(ctx, domain.HashSecret(code, s.cfg.EncKey), dto)
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ExchangeAuthorizationCode consumes code and creates the session it grants
+// once the client, redirect URI and PKCE verifier match the authorization.
+func (s *TokenService) ExchangeAuthorizationCode(
+	ctx context.Context,
+	code string,
+	client domain.ClientDTO,
+	redirectURI string,
+	codeVerifier string,
+) (*domain.AuthTokenDTO, *domain.AuthorizationCodeDTO, error) {
+	if code == "" {
+		return nil, nil, pkg.ErrGrantInvalid
+	}
+	dto, err := s.rep.ICode.TakeAuthorizationCode(ctx, domain.HashSecret(code, s.cfg.EncKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	if dto == nil || dto.ExpiresAt.Before(time.Now().UTC()) {
+		return nil, nil, pkg.ErrGrantInvalid
+	}
+	if dto.ClientID != client.ID || dto.RedirectURI != redirectURI || !dto.VerifyCodeVerifier(codeVerifier) {
+		return nil, nil, pkg.ErrGrantInvalid
+	}
+	if domain.IsOpenID(dto.Scope) && !s.IDTokenEnabled() {
+		return nil, nil, pkg.ErrIDTokenKeyMissing
+	}
+
+	res, err := s.createGranted(ctx, dto.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, dto, nil
+}
+
+// createGranted creates the session of a grant approved earlier.
+func (s *TokenService) createGranted(
+	ctx context.Context,
+	token domain.TokenDTO,
+) (*domain.AuthTokenDTO, error) {
+	return s.Create(ctx, s.granted(token))
+}
+
+// granted re-times token so the session starts when the grant is redeemed
+// rather than when it was approved.
+func (s *TokenService) granted(
+	token domain.TokenDTO,
+) domain.TokenDTO {
+	validity := token.Lifetime
+	if validity == 0 {
+		validity = s.cfg.Validity(token.Role, token.UniqueKey)
+	}
+	token.Refresh(validity)
+	token.StartedAt = token.CreatedAt
+	return token
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+// subjectClaim is the sub of dto, a keyed hash of the auth ID when the
+// configuration keeps auth IDs out of the tokens.
+func (s *TokenService) subjectClaim(
+	dto domain.TokenDTO,
+) string {
+	if s.cfg.HashSubject {
+		return domain.HashSecret(string(dto.AuthID), s.cfg.EncKey)
+	}
+	return string(dto.AuthID)
+}
+
+// actorClaim is the act claim of the delegation chain of actor, hashed like
+// the subject.
+func (s *TokenService) actorClaim(
+	actor *domain.ActorDTO,
+) *domain.ActorClaim {
+	if actor == nil {
+		return nil
+	}
+	return &domain.ActorClaim{
+		Subject: s.subjectClaim(domain.TokenDTO{AuthID: actor.AuthID}),
+		Act:     s.actorClaim(actor.Act),
+	}
+}
+
+// audienceClaim targets the audiences requested for the token, by default
+// the service itself.
+func (s *TokenService) audienceClaim(
+	dto domain.TokenDTO,
+) jwt.ClaimStrings {
+	if len(dto.Audience) > 0 {
+		return dto.Audience
+	}
+	if s.cfg.Audience != "" {
+		return jwt.ClaimStrings{s.cfg.Audience}
+	}
+	return nil
+}
+
+// parserOptions restricts the accepted algorithm and, when configured, the
+// audience so tokens minted for another service are rejected.
+func (s *TokenService) parserOptions() []jwt.ParserOption {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{s.cfg.SigningMethod}),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.cfg.ClockSkew),
+	}
+	// legacy tokens without iss are checked by verifyRegisteredClaims
+	if !s.cfg.AcceptLegacy {
+		options = append(options, jwt.WithIssuer(s.cfg.Issuer))
+	}
+	if s.cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(s.cfg.Audience))
+	}
+	return options
+}
+
+// verifyRegisteredClaims applies the issuer and audience checks of
+// parserOptions to claims parsed without claims validation. Legacy tokens
+// have no iss.
+func (s *TokenService) verifyRegisteredClaims(
+	claims *domain.JWTCustomClaims,
+) error {
+	if claims.Issuer != s.cfg.Issuer && !(s.cfg.AcceptLegacy && claims.Issuer == "") {
+		return jwt.ErrTokenInvalidIssuer
+	}
+	if s.cfg.Audience != "" && !slices.Contains(claims.Audience, s.cfg.Audience) {
+		return jwt.ErrTokenInvalidAudience
+	}
+	return nil
+}
+
+// subjectMatches rejects tokens whose sub does not belong to the stored
+// session. Tokens without sub predate the registered claims and are only
+// accepted with AcceptLegacy.
+func (s *TokenService) subjectMatches(
+	claims *domain.JWTCustomClaims,
+	dto domain.TokenDTO,
+) bool {
+	if claims.Subject == "" {
+		return s.cfg.AcceptLegacy
+	}
+	return claims.Subject == s.subjectClaim(dto)
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+const clientSecretSize = 32
+
+// RegisterClient stores a new client and returns it with its secret, the
+// secret is only kept hashed and cannot be read back. Public clients get none.
+func (s *TokenService) RegisterClient(
+	ctx context.Context,
+	dto domain.ClientDTO,
+) (*domain.ClientDTO, string, error) {
+	dto.ID = domain.ClientID("svc-" + uuid.New().String())
+	dto.CreatedAt = time.Now().UTC()
+	secret := ""
+	if !dto.Public {
+		var err error
+		secret, err = domain.RandomString(clientSecretSize)
+		if err != nil {
+			return nil, "", err
+		}
+		dto.Rotate(domain.HashSecret(secret, s.cfg.EncKey), 0)
+	}
+
+	err := s.rep.IClient.Save(ctx, dto)
+	if err != nil {
+		return nil, "", err
+	}
+	return &dto, secret, nil
+}
+
+// RotateClientSecret issues a new secret, the previous ones stay valid for grace.
+func (s *TokenService) RotateClientSecret(
+	ctx context.Context,
+	id domain.ClientID,
+	grace time.Duration,
+) (string, error) {
+	dto, err := s.rep.IClient.GetById(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if dto == nil || dto.Public {
+		return "", pkg.ErrClientInvalid
+	}
+	secret, err := domain.RandomString(clientSecretSize)
+	if err != nil {
+		return "", err
+	}
+	dto.Rotate(domain.HashSecret(secret, s.cfg.EncKey), grace)
+
+	err = s.rep.IClient.Save(ctx, *dto)
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// VerifyClient authenticates a client, public clients pass an empty secret.
+func (s *TokenService) VerifyClient(
+	ctx context.Context,
+	id domain.ClientID,
+	secret string,
+) (*domain.ClientDTO, error) {
+	dto, err := s.rep.IClient.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dto == nil {
+		return nil, pkg.ErrClientInvalid
+	}
+	// public clients cannot keep a secret, they prove themselves with PKCE
+	if dto.Public && secret != "" || !dto.Public && !dto.Verify(secret, s.cfg.EncKey) {
+		return nil, pkg.ErrClientInvalid
+	}
+	return dto, nil
+}
+
+// DeleteClient removes the registration and invalidates the issued tokens.
+func (s *TokenService) DeleteClient(
+	ctx context.Context,
+	id domain.ClientID,
+) error {
+	_, err := s.rep.IClient.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.Invalidate(ctx, domain.AuthID(id))
+}
+
+func (s *TokenService) GetClient(
+	ctx context.Context,
+	id domain.ClientID,
+) (*domain.ClientDTO, error) {
+	dto, err := s.rep.IClient.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dto == nil {
+		return nil, pkg.ErrClientInvalid
+	}
+	return dto, nil
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+const deviceCodeSize = 32
+
+// CreateDeviceCode starts a device authorization and returns the device code
+// polled by the device with dto carrying the user code.
+func (s *TokenService) CreateDeviceCode(
+	ctx context.Context,
+	dto domain.DeviceCodeDTO,
+) (string, *domain.DeviceCodeDTO, error) {
+	deviceCode, err := domain.RandomString(deviceCodeSize)
+	if err != nil {
+		return "", nil, err
+	}
+	dto.UserCode, err = domain.NewUserCode()
+	if err != nil {
+		return "", nil, err
+	}
+	dto.Status = domain.DeviceCodePending
+	dto.Interval = s.cfg.DeviceInterval
+	dto.ExpiresAt = time.Now().UTC().Add(s.cfg.DeviceValidity)
+
+	err = s.rep.ICode.AddDeviceCode(ctx, domain.HashSecret(deviceCode, s.cfg.EncKey), dto)
+	if err != nil {
+		return "", nil, err
+	}
+	return deviceCode, &dto, nil
+}
+
+// GetDeviceCode returns the pending authorization behind userCode.
+func (s *TokenService) GetDeviceCode(
+	ctx context.Context,
+	userCode string,
+) (string, *domain.DeviceCodeDTO, error) {
+	codeHash, dto, err := s.rep.ICode.GetDeviceCodeByUserCode(ctx, userCode)
+	if err != nil {
+		return "", nil, err
+	}
+	if dto == nil || dto.Status != domain.DeviceCodePending || dto.ExpiresAt.Before(time.Now().UTC()) {
+		return "", nil, pkg.ErrGrantInvalid
+	}
+	return codeHash, dto, nil
+}
+
+// DecideDeviceCode approves the code for the session described by token, or
+// denies it when token is nil.
+func (s *TokenService) DecideDeviceCode(
+	ctx context.Context,
+	userCode string,
+	token *domain.TokenDTO,
+) error {
+	codeHash, dto, err := s.GetDeviceCode(ctx, userCode)
+	if err != nil {
+		return err
+	}
+	dto.Status = domain.DeviceCodeDenied
+	if token != nil {
+		dto.Status = domain.DeviceCodeApproved
+		dto.Token = *token
+		dto.Token.UniqueKey = dto.DeviceID
+		dto.Token.Scopes = strings.Fields(dto.Scope)
+	}
+	return s.rep.ICode.SaveDeviceCode(ctx, codeHash, *dto)
+}
+
+// PollDeviceCode answers a device polling the token endpoint and creates the
+// session once the user approved the code.
+func (s *TokenService) PollDeviceCode(
+	ctx context.Context,
+	deviceCode string,
+	client domain.ClientDTO,
+) (*domain.AuthTokenDTO, *domain.DeviceCodeDTO, error) {
+	if deviceCode == "" {
+		return nil, nil, pkg.ErrGrantInvalid
+	}
+	dto, poll, err := s.rep.ICode.PollDeviceCode(ctx, domain.HashSecret(deviceCode, s.cfg.EncKey), client.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch poll {
+	case domain.DevicePollNotFound:
+		return nil, nil, pkg.ErrGrantInvalid
+	case domain.DevicePollPending:
+		return nil, nil, pkg.ErrAuthorizationPending
+	case domain.DevicePollSlowDown:
+		return nil, nil, pkg.ErrSlowDown
+	case domain.DevicePollDenied:
+		return nil, nil, pkg.ErrAccessDenied
+	case domain.DevicePollExpired:
+		return nil, nil, pkg.ErrDeviceCodeExpired
+	}
+
+	res, err := s.createGranted(ctx, dto.Token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, dto, nil
+}
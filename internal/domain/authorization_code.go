@@ -0,0 +1,45 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"slices"
+	"strings"
+	"time"
+)
+
+const CodeChallengeMethodS256 = "S256"
+
+// AuthorizationCodeDTO is the grant behind a single use authorization code,
+// stored under the hash of the code.
+type AuthorizationCodeDTO struct {
+	ClientID            ClientID  `json:"client_id"`
+	RedirectURI         string    `json:"redirect_uri"`
+	Scope               string    `json:"scope,omitempty"`
+	Nonce               string    `json:"nonce,omitempty"`
+	CodeChallenge       string    `json:"code_challenge"`
+	CodeChallengeMethod string    `json:"code_challenge_method"`
+	Token               TokenDTO  `json:"token"`
+	AuthTime            time.Time `json:"auth_time"`
+	ExpiresAt           time.Time `json:"expires_at"`
+}
+
+// IsOpenID reports whether scope requests an OpenID Connect ID token.
+func IsOpenID(
+	scope string,
+) bool {
+	return slices.Contains(strings.Fields(scope), "openid")
+}
+
+// VerifyCodeVerifier checks the PKCE verifier against the S256 challenge.
+func (entity *AuthorizationCodeDTO) VerifyCodeVerifier(
+	verifier string,
+) bool {
+	if entity.CodeChallengeMethod != CodeChallengeMethodS256 || verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(entity.CodeChallenge)) == 1
+}
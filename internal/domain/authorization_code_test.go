@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVerifyCodeVerifier(t *testing.T) {
+	// RFC 7636 Appendix B
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	code := AuthorizationCodeDTO{
+		CodeChallenge:       "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		CodeChallengeMethod: CodeChallengeMethodS256,
+	}
+	if !code.VerifyCodeVerifier(verifier) {
+		t.Error("RFC 7636 verifier rejected")
+	}
+	for _, wrong := range []string{"", verifier[1:], verifier + "x", code.CodeChallenge} {
+		if code.VerifyCodeVerifier(wrong) {
+			t.Errorf("verifier %q accepted", wrong)
+		}
+	}
+	plain := AuthorizationCodeDTO{CodeChallenge: verifier, CodeChallengeMethod: "plain"}
+	if plain.VerifyCodeVerifier(verifier) {
+		t.Error("plain challenge accepted")
+	}
+}
+
+func TestGrantedScopes(t *testing.T) {
+	client := ClientDTO{Scopes: []string{"read", "write"}}
+	tests := []struct {
+		name      string
+		requested []string
+		granted   []string
+		allowed   bool
+	}{
+		{"registered scope", []string{"read"}, []string{"read"}, true},
+		{"openid", []string{"openid", "read"}, []string{"openid", "read"}, true},
+		{"none requested", nil, []string{"read", "write"}, true},
+		{"unregistered scope", []string{"read", "admin"}, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			granted, allowed := client.GrantedScopes(test.requested)
+			if allowed != test.allowed || !slices.Equal(granted, test.granted) {
+				t.Fatalf("GrantedScopes = %v, %v, want %v, %v", granted, allowed, test.granted, test.allowed)
+			}
+		})
+	}
+}
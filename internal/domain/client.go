@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// ClientDTO is an OAuth2 client. Service accounts carry the Role of their
+// tokens, applications list their RedirectURIs. Public clients have no
+// secret and must use PKCE.
+type ClientDTO struct {
+	ID           ClientID          `json:"id"`
+	Name         string            `json:"name"`
+	Role         string            `json:"role"`
+	GrantTypes   []string          `json:"grant_types,omitempty"`
+	RedirectURIs []string          `json:"redirect_uris,omitempty"`
+	Scopes       []string          `json:"scopes,omitempty"`
+	Public       bool              `json:"public,omitempty"`
+	Secrets      []ClientSecretDTO `json:"secrets"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// AllowsGrant reports whether the client may use grantType, clients stored
+// before grant types were recorded are service accounts.
+func (entity *ClientDTO) AllowsGrant(
+	grantType string,
+) bool {
+	if len(entity.GrantTypes) == 0 {
+		return grantType == GrantTypeClientCredentials
+	}
+	return slices.Contains(entity.GrantTypes, grantType)
+}
+
+// GrantedScopes returns the scopes of a token requested by the client, its
+// registered scopes when it requests none. openid only asks for an ID token
+// and is always allowed.
+func (entity *ClientDTO) GrantedScopes(
+	requested []string,
+) ([]string, bool) {
+	if len(requested) == 0 {
+		return entity.Scopes, true
+	}
+	for _, scope := range requested {
+		if scope != "openid" && !slices.Contains(entity.Scopes, scope) {
+			return nil, false
+		}
+	}
+	return requested, true
+}
+
+func (entity *ClientDTO) AllowsRedirectURI(
+	redirectURI string,
+) bool {
+	return redirectURI != "" && slices.Contains(entity.RedirectURIs, redirectURI)
+}
+
+// ClientSecretDTO keeps only the hash of a secret. A zero ExpiresAt never
+// expires, rotation sets it on the previous secrets to give callers a grace period.
+type ClientSecretDTO struct {
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func (entity *ClientSecretDTO) IsExpired(
+	now time.Time,
+) bool {
+	return !entity.ExpiresAt.IsZero() && entity.ExpiresAt.Before(now)
+}
+
+// Rotate adds a secret and limits the remaining validity of the previous
+// ones to grace, dropping the secrets that already expired.
+func (entity *ClientDTO) Rotate(
+	hash string,
+	grace time.Duration,
+) {
+	now := time.Now().UTC()
+	secrets := make([]ClientSecretDTO, 0, len(entity.Secrets)+1)
+	for _, secret := range entity.Secrets {
+		if secret.IsExpired(now) {
+			continue
+		}
+		if secret.ExpiresAt.IsZero() || secret.ExpiresAt.After(now.Add(grace)) {
+			secret.ExpiresAt = now.Add(grace)
+		}
+		secrets = append(secrets, secret)
+	}
+	entity.Secrets = append(secrets, ClientSecretDTO{Hash: hash, CreatedAt: now})
+}
+
+func (entity *ClientDTO) Verify(
+	secret string,
+	key []byte,
+) bool {
+	now := time.Now().UTC()
+	matched := false
+	// every secret is compared to keep the timing independent of the match position
+	for _, stored := range entity.Secrets {
+		if SecretMatches(secret, stored.Hash, key) && !stored.IsExpired(now) {
+			matched = true
+		}
+	}
+	return matched
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"context"
+)
+
+type contextKey string
+
+const trackingIDKey contextKey = "trackingId"
+
+func WithTrackingID(
+	ctx context.Context,
+	trackingID string,
+) context.Context {
+	return context.WithValue(ctx, trackingIDKey, trackingID)
+}
+
+func TrackingID(
+	ctx context.Context,
+) string {
+	trackingID, _ := ctx.Value(trackingIDKey).(string)
+	return trackingID
+}
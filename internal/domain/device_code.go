@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type DeviceCodeStatus string
+
+const (
+	DeviceCodePending  DeviceCodeStatus = "pending"
+	DeviceCodeApproved DeviceCodeStatus = "approved"
+	DeviceCodeDenied   DeviceCodeStatus = "denied"
+)
+
+// DevicePoll is the outcome of a token request polling a device code.
+type DevicePoll int
+
+const (
+	DevicePollPending DevicePoll = iota
+	DevicePollSlowDown
+	DevicePollApproved
+	DevicePollDenied
+	DevicePollExpired
+	// DevicePollNotFound is an unknown device code or one issued to another
+	// client.
+	DevicePollNotFound
+)
+
+// slowDownStep is added to the interval of a client polling too fast, see
+// RFC 8628 section 3.5.
+const slowDownStep = 5 * time.Second
+
+// userCodeAlphabet has no vowels and no look-alike characters, RFC 8628
+// section 6.1.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// DeviceCodeDTO is a device authorization stored under the hash of the device
+// code. Token is the session granted once the user approves it.
+type DeviceCodeDTO struct {
+	ClientID     ClientID         `json:"client_id"`
+	UserCode     string           `json:"user_code"`
+	Scope        string           `json:"scope,omitempty"`
+	DeviceID     string           `json:"device_id"`
+	Status       DeviceCodeStatus `json:"status"`
+	Token        TokenDTO         `json:"token,omitempty"`
+	Interval     time.Duration    `json:"interval"`
+	LastPolledAt time.Time        `json:"last_polled_at,omitempty"`
+	ExpiresAt    time.Time        `json:"expires_at"`
+}
+
+// Poll records a token request and tells the client how to proceed.
+func (entity *DeviceCodeDTO) Poll(
+	now time.Time,
+) DevicePoll {
+	if entity.ExpiresAt.Before(now) {
+		return DevicePollExpired
+	}
+	tooFast := !entity.LastPolledAt.IsZero() && now.Sub(entity.LastPolledAt) < entity.Interval
+	entity.LastPolledAt = now
+	if tooFast {
+		entity.Interval += slowDownStep
+		return DevicePollSlowDown
+	}
+	switch entity.Status {
+	case DeviceCodeApproved:
+		return DevicePollApproved
+	case DeviceCodeDenied:
+		return DevicePollDenied
+	}
+	return DevicePollPending
+}
+
+// NewUserCode returns a code such as WDJB-MJHT for the user to type.
+func NewUserCode() (string, error) {
+	code := make([]byte, 0, userCodeLength+1)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < userCodeLength; i++ {
+		if i == userCodeLength/2 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code = append(code, userCodeAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
+
+// NormalizeUserCode drops the separators and case the user may type.
+func NormalizeUserCode(
+	userCode string,
+) string {
+	return strings.Map(
+		func(r rune) rune {
+			if r == '-' || r == ' ' {
+				return -1
+			}
+			return r
+		}, strings.ToUpper(userCode),
+	)
+}
@@ -1,18 +1,116 @@
 package domain
 
 import (
+	"crypto/rsa"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type TokenDTO struct {
-	ID        TokenID   `json:"id"`
-	AuthID    AuthID    `json:"auth_id"`
-	Role      string    `json:"role"`
-	UniqueKey string    `json:"unique_key"`
-	ExpiresAt time.Time `json:"expires_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        TokenID       `json:"id"`
+	AuthID    AuthID        `json:"auth_id"`
+	Role      string        `json:"role"`
+	UniqueKey string        `json:"unique_key"`
+	Subject   SubjectType   `json:"subject_type,omitempty"`
+	Format    TokenFormat   `json:"format,omitempty"`
+	Audience  []string      `json:"audience,omitempty"`
+	Lifetime  time.Duration `json:"lifetime,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	CreatedAt time.Time     `json:"created_at"`
+
+	LastSeenAt        time.Time `json:"last_seen_at,omitempty"`
+	LastSeenIP        string    `json:"last_seen_ip,omitempty"`
+	LastSeenUserAgent string    `json:"last_seen_user_agent,omitempty"`
+
+	// AuthTime is when the user last authenticated, AuthMethods (RFC 8176 amr
+	// values) and AuthLevel how strongly.
+	AuthTime    time.Time `json:"auth_time,omitempty"`
+	AuthMethods []string  `json:"amr,omitempty"`
+	AuthLevel   int       `json:"auth_level,omitempty"`
+
+	// Scopes restrict exchanged and OAuth client tokens, nil is unrestricted.
+	// Actor is the delegation chain of the exchange.
+	Scopes    []string  `json:"scopes,omitempty"`
+	Actor     *ActorDTO `json:"act,omitempty"`
+	Exchanged bool      `json:"exchanged,omitempty"`
+}
+
+func (entity *TokenDTO) SubjectType() SubjectType {
+	if entity.Subject == "" {
+		return SubjectUser
+	}
+	return entity.Subject
+}
+
+// Refreshable is false for tokens re-issued through their grant instead, such
+// as service and exchanged tokens.
+func (entity *TokenDTO) Refreshable() bool {
+	return entity.SubjectType() != SubjectService && !entity.Exchanged
+}
+
+// AuthenticatedAt is AuthTime, or the session start for tokens stored before
+// the authentication time was recorded.
+func (entity *TokenDTO) AuthenticatedAt() time.Time {
+	if entity.AuthTime.IsZero() {
+		return entity.StartedAt
+	}
+	return entity.AuthTime
+}
+
+// Elevate records an additional authentication by method, the level never
+// decreases.
+func (entity *TokenDTO) Elevate(
+	method string,
+	level int,
+	at time.Time,
+) {
+	entity.AuthTime = at
+	if !slices.Contains(entity.AuthMethods, method) {
+		entity.AuthMethods = append(entity.AuthMethods, method)
+	}
+	entity.AuthLevel = max(entity.AuthLevel, level)
+}
+
+// LastUsedAt returns the last time the session was issued, refreshed or seen.
+func (entity *TokenDTO) LastUsedAt() time.Time {
+	if entity.LastSeenAt.After(entity.CreatedAt) {
+		return entity.LastSeenAt
+	}
+	return entity.CreatedAt
+}
+
+func (entity *TokenDTO) IsIdle(
+	timeout time.Duration,
+) bool {
+	if timeout <= 0 {
+		return false
+	}
+	return time.Now().UTC().Sub(entity.LastUsedAt()) > timeout
+}
+
+// Touch records the activity and reports whether it is worth persisting,
+// i.e. the last recorded activity is older than throttle.
+func (entity *TokenDTO) Touch(
+	activity SessionActivity,
+	throttle time.Duration,
+) bool {
+	if activity.SeenAt.Sub(entity.LastSeenAt) < throttle {
+		return false
+	}
+	entity.LastSeenAt = activity.SeenAt
+	entity.LastSeenIP = activity.IP
+	entity.LastSeenUserAgent = activity.UserAgent
+	return true
+}
+
+type SessionActivity struct {
+	IP        string
+	UserAgent string
+	SeenAt    time.Time
 }
 
 func (entity *TokenDTO) Refresh(
@@ -59,15 +157,151 @@ type JWTPayload struct {
 	ExpireAt int64  `json:"expireAt"`
 }
 
+// JWTCustomClaims carries the token ID in jti, LegacyID is the custom id claim
+// of tokens issued before the registered claims were used.
 type JWTCustomClaims struct {
-	ID   string `json:"id"`
-	Role string `json:"role"`
+	LegacyID string           `json:"id,omitempty"`
+	Role     string           `json:"role"`
+	Scope    string           `json:"scope,omitempty"`
+	Act      *ActorClaim      `json:"act,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
+	ACR      string           `json:"acr,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// TokenID returns the jti, or the id claim of legacy tokens when acceptLegacy.
+func (claims *JWTCustomClaims) TokenID(
+	acceptLegacy bool,
+) TokenID {
+	if claims.ID == "" && acceptLegacy {
+		return TokenID(claims.LegacyID)
+	}
+	return TokenID(claims.ID)
+}
+
 type TokenConfig struct {
 	JwtKey            []byte
 	EncKey            []byte
 	EncIV             []byte
 	JwtValidityInMins time.Duration
+	Lifetimes         map[string]time.Duration
+	SessionLimits     map[string]SessionLimit
+	ReplacedOverlap   time.Duration
+	IdleTimeouts      map[string]time.Duration
+	ActivityThrottle  time.Duration
+	APIKeyPrefix      string
+	TokenFormat       TokenFormat
+	TokenEncryption   TokenEncryption
+	AcceptUnencrypted bool
+	JWEPrivateKey     *rsa.PrivateKey
+	SigningMethod     string
+	Issuer            string
+	Audience          string
+	ClockSkew         time.Duration
+	HashSubject       bool
+	AcceptLegacy      bool
+	IDTokenKey        *rsa.PrivateKey
+	IDTokenKeyID      string
+	IDTokenValidity   time.Duration
+	CodeValidity      time.Duration
+	DeviceValidity    time.Duration
+	DeviceInterval    time.Duration
+	ExchangeRoles     map[string][]string
+	ExchangeAudiences map[string][]string
+	ActorRoles        []string
+	ExchangeValidity  time.Duration
+	TOTPIssuer        string
+	TOTPSkew          int
+	TOTPMaxFailures   int
+	TOTPLockout       time.Duration
+	MFAValidity       time.Duration
+	OTPValidity       time.Duration
+	OTPLength         int
+	OTPMaxAttempts    int
+	OTPLockout        time.Duration
+	RateLimits        map[RateLimitScope]RateLimit
+	LockoutFailures   int
+	LockoutWindow     time.Duration
+	LockoutDuration   time.Duration
+}
+
+func (cfg *TokenConfig) IdleTimeout(
+	role string,
+) time.Duration {
+	if timeout, found := cfg.IdleTimeouts[role]; found {
+		return timeout
+	}
+	return cfg.IdleTimeouts[""]
+}
+
+type SessionLimitPolicy int
+
+const (
+	// SessionLimitReject fails the new login with pkg.ErrSessionLimitReached
+	SessionLimitReject SessionLimitPolicy = iota
+	// SessionLimitEvictOldest revokes the sessions started first
+	SessionLimitEvictOldest
+	// SessionLimitEvictLeastRecentlyUsed revokes the sessions used last the longest ago
+	SessionLimitEvictLeastRecentlyUsed
+)
+
+// SessionLimit caps the concurrent sessions of an auth ID. An empty Role
+// counts every session of the auth ID, otherwise only sessions of that role.
+type SessionLimit struct {
+	Role   string
+	Max    int
+	Policy SessionLimitPolicy
+}
+
+// RateLimit allows Limit calls per key within any sliding Window.
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// SessionPolicy is applied by the repository when a session is stored.
+// ReplacedOverlap keeps the access token of a replaced unique key alive for
+// in-flight requests, zero revokes it immediately.
+type SessionPolicy struct {
+	Limit           SessionLimit
+	ReplacedOverlap time.Duration
+}
+
+func (cfg *TokenConfig) SessionPolicy(
+	role string,
+) SessionPolicy {
+	policy := SessionPolicy{
+		Limit:           cfg.SessionLimits[""],
+		ReplacedOverlap: cfg.ReplacedOverlap,
+	}
+	if limit, found := cfg.SessionLimits[role]; found {
+		policy.Limit = limit
+	}
+	return policy
+}
+
+func LifetimeKey(
+	role string,
+	uniqueKey string,
+) string {
+	if uniqueKey == "" {
+		return fmt.Sprintf("ro::%s", role)
+	}
+	return fmt.Sprintf("ro::%s::uk::%s", role, uniqueKey)
+}
+
+// Validity resolves the token lifetime for a role and unique key, falling back
+// from the role/unique key policy to the role policy and then to JwtValidityInMins.
+func (cfg *TokenConfig) Validity(
+	role string,
+	uniqueKey string,
+) time.Duration {
+	if validity, found := cfg.Lifetimes[LifetimeKey(role, uniqueKey)]; found {
+		return validity
+	}
+	if validity, found := cfg.Lifetimes[LifetimeKey(role, "")]; found {
+		return validity
+	}
+	return cfg.JwtValidityInMins
 }
@@ -4,6 +4,10 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 )
@@ -53,6 +57,37 @@ func Aes256Decode(
 	return string(cipherTextDecoded), nil
 }
 
+// HashSecret returns the hex HMAC-SHA256 of a high entropy secret, used to
+// store client secrets and keys without keeping them in clear.
+func HashSecret(
+	secret string,
+	key []byte,
+) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(secret))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func SecretMatches(
+	secret string,
+	hash string,
+	key []byte,
+) bool {
+	return hmac.Equal([]byte(HashSecret(secret, key)), []byte(hash))
+}
+
+// RandomString returns size random bytes encoded as unpadded base64url.
+func RandomString(
+	size int,
+) (string, error) {
+	buf := make([]byte, size)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
 func pkcs5Padding(
 	ciphertext []byte,
 	blockSize int,
@@ -0,0 +1,18 @@
+package domain
+
+import (
+	"time"
+)
+
+// IDTokenDTO describes the authentication of AuthID to the clients listed in
+// Audience. AccessToken, when set, is bound to the ID token through at_hash.
+type IDTokenDTO struct {
+	AuthID      AuthID
+	Audience    []string
+	Nonce       string
+	AuthTime    time.Time
+	ACR         string
+	AMR         []string
+	Profile     map[string]interface{}
+	AccessToken string
+}
@@ -1,110 +1,67 @@
 package domain
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"os"
-	"runtime"
-	"strings"
-	"sync"
-
-	"github.com/sirupsen/logrus"
 )
 
-var client *logrus.Logger
+const LoggerContextKey contextKey = "httpLogger"
 
-var (
-	callerInitOnce     sync.Once
-	commonPackage      string
-	minimumCallerDepth int
-	maximumCallerDepth = 10
-)
+var client *slog.Logger
 
-func getPackageName(funcName string) string {
-	lastSlash := strings.LastIndex(funcName, "/")
-	if lastSlash == -1 {
-		return ""
-	}
-	secondLastSlash := strings.LastIndex(funcName[:lastSlash], "/")
-	if secondLastSlash == -1 {
-		return funcName[:lastSlash]
+// NewLoggerClient sets the logger used by the package. A nil logger falls back
+// to JSON on stderr with the ECS style keys the package has always emitted.
+func NewLoggerClient(
+	logger *slog.Logger,
+) {
+	if logger == nil {
+		logger = slog.New(
+			slog.NewJSONHandler(
+				os.Stderr, &slog.HandlerOptions{
+					AddSource: true,
+					Level:     slog.LevelInfo,
+					ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+						if len(groups) > 0 {
+							return attr
+						}
+						switch attr.Key {
+						case slog.TimeKey:
+							attr.Key = "@timestamp"
+						case slog.MessageKey:
+							attr.Key = "message"
+						}
+						return attr
+					},
+				},
+			),
+		)
 	}
-	return funcName[secondLastSlash+1 : lastSlash]
+	client = logger.With("pkg", PkgKeyword)
 }
 
-func getCaller() *runtime.Frame {
-	callerInitOnce.Do(
-		func() {
-			pcs := make([]uintptr, maximumCallerDepth)
-			_ = runtime.Callers(0, pcs)
-
-			for i := 0; i < maximumCallerDepth; i++ {
-				funcName := runtime.FuncForPC(pcs[i]).Name()
-				if strings.Contains(funcName, "GetCaller") {
-					commonPackage = getPackageName(funcName)
-					break
-				}
-			}
-
-			minimumCallerDepth = 3
-		},
-	)
-
-	pcs := make([]uintptr, maximumCallerDepth)
-	depth := runtime.Callers(minimumCallerDepth, pcs)
-	frames := runtime.CallersFrames(pcs[:depth])
-
-	commonPackageFound := false
-	for f, again := frames.Next(); again; f, again = frames.Next() {
-		pkg := getPackageName(f.Function)
-
-		if strings.Contains(pkg, commonPackage) {
-			commonPackageFound = true
-		}
-		if !strings.Contains(pkg, commonPackage) && commonPackageFound {
-			return &f
-		}
+func Logger() *slog.Logger {
+	if client == nil {
+		return slog.Default()
 	}
-
-	return nil
+	return client
 }
 
-func NewLoggerClient(
-	level logrus.Level,
-) {
-	client = &logrus.Logger{
-		Out:   os.Stderr,
-		Hooks: make(logrus.LevelHooks),
-		Level: level,
-		Formatter: &logrus.JSONFormatter{
-			FieldMap: logrus.FieldMap{
-				logrus.FieldKeyTime:  "@timestamp",
-				logrus.FieldKeyLevel: "level",
-				logrus.FieldKeyMsg:   "message",
-				logrus.FieldKeyFunc:  "function_name", // non-ECS
-			},
-			CallerPrettyfier: func(*runtime.Frame) (function string, file string) {
-				frame := getCaller()
-				if frame == nil {
-					return
-				}
-				pc := frame.Entry
-				file = frame.File
-				line := frame.Line
-
-				funcInfo := runtime.FuncForPC(pc)
-				if funcInfo == nil {
-					return
-				}
-				filename := fmt.Sprintf("%s:%d", file, line)
-
-				return funcInfo.Name(), filename
-			},
-		},
-	}
-
-	client.SetReportCaller(true)
+func WithLogger(
+	ctx context.Context,
+	logger *slog.Logger,
+) context.Context {
+	return context.WithValue(ctx, LoggerContextKey, logger)
 }
 
-func Logger() *logrus.Logger {
-	return client
+// LoggerFromContext returns the request scoped logger, or the package logger
+// when the request did not go through the middlewares.
+func LoggerFromContext(
+	ctx context.Context,
+) *slog.Logger {
+	logger, ok := ctx.Value(LoggerContextKey).(*slog.Logger)
+	if ok && logger != nil {
+		return logger
+	}
+	return Logger()
 }
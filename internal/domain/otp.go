@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// OTPDTO is a one-time passcode challenge, CodeHash is empty for magic links
+// whose signed token is the proof.
+type OTPDTO struct {
+	Token     TokenDTO  `json:"token"`
+	Channel   string    `json:"channel"`
+	Recipient string    `json:"recipient"`
+	CodeHash  string    `json:"code_hash,omitempty"`
+	MagicLink bool      `json:"magic_link,omitempty"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewNumericCode returns a uniformly random code of length digits.
+func NewNumericCode(
+	length int,
+) (string, error) {
+	var code strings.Builder
+	for i := 0; i < length; i++ {
+		digit, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(byte('0' + digit.Int64()))
+	}
+	return code.String(), nil
+}
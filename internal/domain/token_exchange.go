@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// ActorDTO is the party acting on behalf of the subject of an exchanged token,
+// Act holds the actor of the exchanged subject token and so on down the chain.
+type ActorDTO struct {
+	AuthID  AuthID      `json:"auth_id"`
+	Role    string      `json:"role"`
+	Subject SubjectType `json:"subject_type,omitempty"`
+	Act     *ActorDTO   `json:"act,omitempty"`
+}
+
+// ActorClaim is the act claim of RFC 8693 section 4.1.
+type ActorClaim struct {
+	Subject string      `json:"sub"`
+	Act     *ActorClaim `json:"act,omitempty"`
+}
+
+// TokenExchangeDTO describes the token requested in exchange for a subject
+// token, zero values keep those of the subject token.
+type TokenExchangeDTO struct {
+	Role     string
+	Scopes   []string
+	Audience []string
+	Lifetime time.Duration
+}
+
+// MayExchange reports whether a token of role may be exchanged for a token of
+// target, a token can always keep its role.
+func (cfg *TokenConfig) MayExchange(
+	role string,
+	target string,
+) bool {
+	return role == target || slices.Contains(cfg.ExchangeRoles[role], target)
+}
+
+// MayExchangeAudience reports whether a token of role for granted may be
+// exchanged for a token for audience. Each audience must be granted or listed
+// in ExchangeAudiences of role.
+func (cfg *TokenConfig) MayExchangeAudience(
+	role string,
+	audience []string,
+	granted []string,
+) bool {
+	for _, aud := range audience {
+		if !slices.Contains(granted, aud) && !slices.Contains(cfg.ExchangeAudiences[role], aud) {
+			return false
+		}
+	}
+	return true
+}
+
+// MayAct reports whether tokens of role may act on behalf of another subject.
+func (cfg *TokenConfig) MayAct(
+	role string,
+) bool {
+	return slices.Contains(cfg.ActorRoles, role)
+}
+
+// IsSubset reports whether scopes are all granted, nil granted is unrestricted.
+func IsSubset(
+	scopes []string,
+	granted []string,
+) bool {
+	if granted == nil {
+		return true
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
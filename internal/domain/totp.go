@@ -0,0 +1,145 @@
+package domain
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters of RFC 6238 as understood by common authenticator apps.
+const (
+	TOTPDigits     = 6
+	TOTPPeriod     = 30 * time.Second
+	totpSecretSize = 20
+
+	RecoveryCodeCount = 10
+	recoveryCodeSize  = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPDTO is the second factor of an auth ID. Secret is encrypted with the
+// client key, RecoveryCodes hold hashes of the unused recovery codes.
+type TOTPDTO struct {
+	AuthID        AuthID    `json:"auth_id"`
+	Secret        string    `json:"secret"`
+	Confirmed     bool      `json:"confirmed"`
+	LastCounter   int64     `json:"last_counter"`
+	RecoveryCodes []string  `json:"recovery_codes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MFAPendingDTO is a login waiting for its second factor, IDToken is issued
+// along with the session when the login asked for one.
+type MFAPendingDTO struct {
+	Token     TokenDTO    `json:"token"`
+	IDToken   *IDTokenDTO `json:"id_token,omitempty"`
+	Attempts  int         `json:"attempts"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// UseCounter records the time step of a verified code, codes of that step or
+// earlier ones are rejected afterwards so each code is used once.
+func (dto *TOTPDTO) UseCounter(
+	counter int64,
+) bool {
+	if counter <= dto.LastCounter {
+		return false
+	}
+	dto.LastCounter = counter
+	return true
+}
+
+// NewTOTPSecret returns a random base32 secret.
+func NewTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretSize)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// TOTPCode is the HOTP value (RFC 4226) of secret for counter.
+func TOTPCode(
+	secret []byte,
+	counter int64,
+) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1000000)
+}
+
+// MatchTOTP returns the counter of the time step within skew steps of now
+// whose code is code.
+func MatchTOTP(
+	secret string,
+	code string,
+	now time.Time,
+	skew int,
+) (int64, bool) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := now.Unix() / int64(TOTPPeriod.Seconds())
+	for step := -skew; step <= skew; step++ {
+		counter := current + int64(step)
+		if hmac.Equal([]byte(TOTPCode(key, counter)), []byte(code)) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI is the otpauth URI authenticator apps enroll from, usually shown
+// as a QR code.
+func TOTPURI(
+	issuer string,
+	account string,
+	secret string,
+) string {
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(TOTPDigits)},
+		"period":    {fmt.Sprint(int(TOTPPeriod.Seconds()))},
+	}
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return uri.String()
+}
+
+// NewRecoveryCode returns a single use code formatted as xxxxx-xxxxx.
+func NewRecoveryCode() (string, error) {
+	buf := make([]byte, 8)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(buf))[:recoveryCodeSize]
+	return code[:recoveryCodeSize/2] + "-" + code[recoveryCodeSize/2:], nil
+}
+
+// NormalizeRecoveryCode drops the separator and case of a typed recovery code.
+func NormalizeRecoveryCode(
+	code string,
+) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
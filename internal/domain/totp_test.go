@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed of RFC 6238 Appendix B.
+var rfc6238Secret = []byte("12345678901234567890")
+
+// The RFC lists 8 digit values, 6 digit codes are their last 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		counter := vector.unix / int64(TOTPPeriod.Seconds())
+		code := TOTPCode(rfc6238Secret, counter)
+		if code != vector.code {
+			t.Errorf("TOTPCode at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfc6238Secret)
+	for _, vector := range rfc6238Vectors {
+		now := time.Unix(vector.unix, 0)
+		counter, ok := MatchTOTP(secret, vector.code, now, 0)
+		if !ok || counter != vector.unix/int64(TOTPPeriod.Seconds()) {
+			t.Errorf("MatchTOTP at %d = %d, %v", vector.unix, counter, ok)
+		}
+	}
+
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / int64(TOTPPeriod.Seconds())
+	tests := []struct {
+		name  string
+		step  int64
+		skew  int
+		match bool
+	}{
+		{"previous step within skew", -1, 1, true},
+		{"next step within skew", 1, 1, true},
+		{"previous step without skew", -1, 0, false},
+		{"step beyond skew", -2, 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code := TOTPCode(rfc6238Secret, current+test.step)
+			counter, ok := MatchTOTP(secret, code, now, test.skew)
+			if ok != test.match {
+				t.Fatalf("MatchTOTP = %v, want %v", ok, test.match)
+			}
+			if ok && counter != current+test.step {
+				t.Fatalf("MatchTOTP counter = %d, want %d", counter, current+test.step)
+			}
+		})
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := MatchTOTP(secret, code, now, 1); ok {
+			t.Errorf("MatchTOTP accepted %q", code)
+		}
+	}
+}
+
+func TestTOTPUseCounter(t *testing.T) {
+	dto := TOTPDTO{}
+	if !dto.UseCounter(100) {
+		t.Fatal("first use of a counter rejected")
+	}
+	if dto.UseCounter(100) {
+		t.Error("reused counter accepted")
+	}
+	if dto.UseCounter(99) {
+		t.Error("earlier counter accepted")
+	}
+	if !dto.UseCounter(101) {
+		t.Error("later counter rejected")
+	}
+}
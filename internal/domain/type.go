@@ -3,6 +3,67 @@ package domain
 type TokenID string
 type RefreshID string
 type AuthID string
+type ClientID string
+type APIKeyID string
+
+// SubjectType tells who a token was issued to, an empty value is a user.
+type SubjectType string
+
+const (
+	SubjectUser    SubjectType = "user"
+	SubjectService SubjectType = "service"
+)
+
+// TokenFormat selects how access tokens are handed to the bearer. Opaque
+// tokens are random strings whose hash is the stored token ID, so they carry
+// no readable claims.
+type TokenFormat string
+
+const (
+	TokenFormatJWT    TokenFormat = "jwt"
+	TokenFormatOpaque TokenFormat = "opaque"
+)
+
+// TokenEncryption wraps signed JWTs as compact JWE so their claims cannot be
+// read by the bearer, the zero value leaves them signed only.
+type TokenEncryption string
+
+const (
+	TokenEncryptionNone       TokenEncryption = ""
+	TokenEncryptionDirect     TokenEncryption = "dir"
+	TokenEncryptionRSAOAEP256 TokenEncryption = "RSA-OAEP-256"
+)
+
+// OAuth2 grant types handled by the token endpoint.
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// Authentication levels of TokenDTO.AuthLevel.
+const (
+	AuthLevelSingleFactor = 1
+	AuthLevelMultiFactor  = 2
+)
+
+// RFC 8176 authentication methods recorded by the package.
+const (
+	AuthMethodPassword     = "pwd"
+	AuthMethodOTP          = "otp"
+	AuthMethodRecoveryCode = "recovery_code"
+)
+
+// RateLimitScope groups the calls sharing a rate limit.
+type RateLimitScope string
+
+const (
+	RateLimitLogin        RateLimitScope = "login"
+	RateLimitRefresh      RateLimitScope = "refresh"
+	RateLimitOTP          RateLimitScope = "otp"
+	RateLimitAuthenticate RateLimitScope = "authenticate"
+)
 
 const PkgKeyword = "goauth"
 const LogKeyword = "GoAuth"
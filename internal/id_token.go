@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// IDTokenAlgorithm signs ID tokens, clients verify them with the JWKS.
+const IDTokenAlgorithm = "RS256"
+
+// reservedIDTokenClaims cannot be overridden by profile claims.
+var reservedIDTokenClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "iat": true, "nbf": true, "jti": true,
+	"auth_time": true, "nonce": true, "acr": true, "amr": true, "at_hash": true, "azp": true,
+}
+
+// IDTokenEnabled reports whether ID tokens can be signed, checked before the
+// sessions they come with are created.
+func (s *TokenService) IDTokenEnabled() bool {
+	return s.cfg.IDTokenKey != nil
+}
+
+func (s *TokenService) CreateIDToken(
+	dto domain.IDTokenDTO,
+) (string, error) {
+	if s.cfg.IDTokenKey == nil {
+		return "", pkg.ErrIDTokenKeyMissing
+	}
+	now := time.Now().UTC()
+	claims := jwt.MapClaims{}
+	for name, value := range dto.Profile {
+		if !reservedIDTokenClaims[name] {
+			claims[name] = value
+		}
+	}
+	claims["iss"] = s.cfg.Issuer
+	claims["sub"] = s.subjectClaim(domain.TokenDTO{AuthID: dto.AuthID})
+	claims["aud"] = dto.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.cfg.IDTokenValidity).Unix()
+	if len(dto.Audience) == 1 {
+		claims["aud"] = dto.Audience[0]
+	}
+	if !dto.AuthTime.IsZero() {
+		claims["auth_time"] = dto.AuthTime.Unix()
+	}
+	if dto.Nonce != "" {
+		claims["nonce"] = dto.Nonce
+	}
+	if dto.ACR != "" {
+		claims["acr"] = dto.ACR
+	}
+	if len(dto.AMR) > 0 {
+		claims["amr"] = dto.AMR
+	}
+	if dto.AccessToken != "" {
+		claims["at_hash"] = accessTokenHash(dto.AccessToken)
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(IDTokenAlgorithm), claims)
+	token.Header["kid"] = s.idTokenKeyID()
+	return token.SignedString(s.cfg.IDTokenKey)
+}
+
+// JWKS returns the public keys clients use to verify ID tokens.
+func (s *TokenService) JWKS() jose.JSONWebKeySet {
+	keySet := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	if s.cfg.IDTokenKey == nil {
+		return keySet
+	}
+	keySet.Keys = append(keySet.Keys, jose.JSONWebKey{
+		Key:       &s.cfg.IDTokenKey.PublicKey,
+		KeyID:     s.idTokenKeyID(),
+		Algorithm: IDTokenAlgorithm,
+		Use:       "sig",
+	})
+	return keySet
+}
+
+// idTokenKeyID defaults the key ID to the RFC 7638 thumbprint of the key.
+func (s *TokenService) idTokenKeyID() string {
+	if s.cfg.IDTokenKeyID != "" {
+		return s.cfg.IDTokenKeyID
+	}
+	jwk := jose.JSONWebKey{Key: &s.cfg.IDTokenKey.PublicKey}
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint)
+}
+
+// accessTokenHash is the at_hash of OpenID Connect Core section 3.1.3.6.
+func accessTokenHash(
+	accessToken string,
+) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
+
+func (s *TokenService) Issuer() string {
+	return s.cfg.Issuer
+}
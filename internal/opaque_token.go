@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+const opaqueTokenSize = 32
+
+// isOpaqueToken tells opaque tokens apart from JWTs, which always contain dots.
+func isOpaqueToken(
+	token string,
+) bool {
+	return token != "" && !strings.Contains(token, ".")
+}
+
+// opaqueTokenID is the stored ID of an opaque token, only the hash is kept so
+// a leaked store does not leak usable tokens.
+func (s *TokenService) opaqueTokenID(
+	token string,
+) domain.TokenID {
+	return domain.TokenID(domain.HashSecret(token, s.cfg.EncKey))
+}
+
+// assignOpaqueID generates the token of an opaque session and sets its ID,
+// it returns an empty token for JWT sessions.
+func (s *TokenService) assignOpaqueID(
+	dto *domain.TokenDTO,
+) (string, error) {
+	if dto.Format != domain.TokenFormatOpaque {
+		return "", nil
+	}
+	token, err := domain.RandomString(opaqueTokenSize)
+	if err != nil {
+		return "", err
+	}
+	dto.ID = s.opaqueTokenID(token)
+	return token, nil
+}
+
+func (s *TokenService) createAccessToken(
+	dto domain.TokenDTO,
+	opaqueToken string,
+) (string, error) {
+	if dto.Format == domain.TokenFormatOpaque {
+		return opaqueToken, nil
+	}
+	return s.createJWTToken(dto)
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"context"
+	"crypto/hmac"
+	"strings"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+const (
+	otpChallengeSize = 24
+	// otpFailureScope is the window of wrong codes per recipient
+	otpFailureScope = "otp-failure"
+)
+
+// CreateOTP stores a challenge for dto.Token and returns its ID with the
+// secret to deliver, a numeric code or the signed token of a magic link.
+func (s *TokenService) CreateOTP(
+	ctx context.Context,
+	dto domain.OTPDTO,
+) (string, string, *domain.OTPDTO, error) {
+	err := s.checkLock(ctx, s.otpLockout(), s.recipientKey(dto.Recipient))
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	challengeID, err := domain.RandomString(otpChallengeSize)
+	if err != nil {
+		return "", "", nil, err
+	}
+	var secret string
+	if dto.MagicLink {
+		secret = challengeID + "." + s.signChallenge(challengeID)
+	} else {
+		secret, err = domain.NewNumericCode(s.cfg.OTPLength)
+		if err != nil {
+			return "", "", nil, err
+		}
+		dto.CodeHash = domain.HashSecret(challengeID+":"+secret, s.cfg.EncKey)
+	}
+	dto.ExpiresAt = time.Now().UTC().Add(s.cfg.OTPValidity)
+	err = s.rep.ICode.AddOTP(ctx, domain.HashSecret(challengeID, s.cfg.EncKey), dto)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return challengeID, secret, &dto, nil
+}
+
+// VerifyOTP consumes the numeric challenge and returns the token it grants.
+// Wrong codes count against the recipient across all of its challenges, the
+// last allowed one locks the recipient out.
+func (s *TokenService) VerifyOTP(
+	ctx context.Context,
+	challengeID string,
+	code string,
+) (*domain.TokenDTO, error) {
+	challengeHash := domain.HashSecret(challengeID, s.cfg.EncKey)
+	dto, err := s.rep.ICode.TakeOTP(ctx, challengeHash)
+	if err != nil {
+		return nil, err
+	}
+	if dto == nil || dto.MagicLink || dto.ExpiresAt.Before(time.Now().UTC()) {
+		return nil, pkg.ErrOTPInvalid
+	}
+	err = s.checkLock(ctx, s.otpLockout(), s.recipientKey(dto.Recipient))
+	if err != nil {
+		return nil, err
+	}
+	if !domain.SecretMatches(challengeID+":"+strings.TrimSpace(code), dto.CodeHash, s.cfg.EncKey) {
+		return nil, s.failOTP(ctx, challengeHash, *dto)
+	}
+	return s.otpGranted(*dto), nil
+}
+
+// VerifyMagicLink consumes the challenge of a magic link token, forged tokens
+// are rejected by their signature without a lookup.
+func (s *TokenService) VerifyMagicLink(
+	ctx context.Context,
+	linkToken string,
+) (*domain.TokenDTO, error) {
+	challengeID, signature, found := strings.Cut(linkToken, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(s.signChallenge(challengeID))) {
+		return nil, pkg.ErrOTPInvalid
+	}
+	dto, err := s.rep.ICode.TakeOTP(ctx, domain.HashSecret(challengeID, s.cfg.EncKey))
+	if err != nil {
+		return nil, err
+	}
+	if dto == nil || !dto.MagicLink || dto.ExpiresAt.Before(time.Now().UTC()) {
+		return nil, pkg.ErrOTPInvalid
+	}
+	return s.otpGranted(*dto), nil
+}
+
+// failOTP counts a wrong code against the recipient and stores the challenge
+// again with one more attempt. OTPMaxAttempts wrong codes within OTPLockout,
+// whatever challenges they were sent to, lock the recipient out.
+func (s *TokenService) failOTP(
+	ctx context.Context,
+	challengeHash string,
+	dto domain.OTPDTO,
+) error {
+	err := s.recordFailure(ctx, s.otpLockout(), s.recipientKey(dto.Recipient))
+	if err != nil {
+		return err
+	}
+	dto.Attempts++
+	if dto.Attempts < s.cfg.OTPMaxAttempts {
+		err = s.rep.ICode.AddOTP(ctx, challengeHash, dto)
+		if err != nil {
+			return err
+		}
+	}
+	return pkg.ErrOTPInvalid
+}
+
+func (s *TokenService) otpLockout() failureLockout {
+	return failureLockout{
+		Scope:    otpFailureScope,
+		Failures: s.cfg.OTPMaxAttempts,
+		Window:   s.cfg.OTPLockout,
+		Duration: s.cfg.OTPLockout,
+		Err:      pkg.ErrOTPLocked,
+	}
+}
+
+func (s *TokenService) otpGranted(
+	dto domain.OTPDTO,
+) *domain.TokenDTO {
+	token := dto.Token
+	token.Elevate(domain.AuthMethodOTP, domain.AuthLevelSingleFactor, time.Now().UTC())
+	token = s.granted(token)
+	return &token
+}
+
+func (s *TokenService) signChallenge(
+	challengeID string,
+) string {
+	return domain.HashSecret("magic-link:"+challengeID, s.cfg.EncKey)
+}
+
+// recipientKey normalizes recipient, the lockout hashes it.
+func (s *TokenService) recipientKey(
+	recipient string,
+) string {
+	return strings.ToLower(strings.TrimSpace(recipient))
+}
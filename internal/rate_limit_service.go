@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// lockoutScope is the window of failed logins, it is separate from the rate
+// limit scopes so successful calls do not count.
+const lockoutScope = "failure"
+
+// Throttle records a call of scope for each key and fails with
+// pkg.ErrRateLimited once one of them exceeds the limit of scope. Scopes
+// without a limit are not throttled.
+func (s *TokenService) Throttle(
+	ctx context.Context,
+	scope domain.RateLimitScope,
+	keys ...string,
+) error {
+	limit, found := s.cfg.RateLimits[scope]
+	if !found || limit.Limit <= 0 {
+		return nil
+	}
+	for _, key := range keys {
+		retryAfter, err := s.rep.IRateLimit.Hit(
+			ctx, string(scope), domain.HashSecret(key, s.cfg.EncKey), limit.Limit, limit.Window,
+		)
+		if err != nil {
+			return err
+		}
+		if retryAfter > 0 {
+			domain.LoggerFromContext(ctx).Info(domain.LogKeyword+": RateLimited", "scope", scope)
+			return &pkg.RateLimitError{Err: pkg.ErrRateLimited, RetryAfter: retryAfter}
+		}
+	}
+	return nil
+}
+
+// CheckLockout fails with pkg.ErrAccountLocked while account is locked out.
+func (s *TokenService) CheckLockout(
+	ctx context.Context,
+	account string,
+) error {
+	return s.checkLock(ctx, s.loginLockout(), account)
+}
+
+// RecordFailure counts a failed login of account. The failure reaching
+// LockoutFailures within LockoutWindow locks the account out for
+// LockoutDuration and fails with pkg.ErrAccountLocked.
+func (s *TokenService) RecordFailure(
+	ctx context.Context,
+	account string,
+) error {
+	return s.recordFailure(ctx, s.loginLockout(), account)
+}
+
+// ResetFailures forgets the failed logins of account after a successful one.
+func (s *TokenService) ResetFailures(
+	ctx context.Context,
+	account string,
+) error {
+	return s.resetFailures(ctx, s.loginLockout(), account)
+}
+
+// failureLockout locks a key out for Duration once Failures failures were
+// recorded within Window, calls then fail with Err. Zero Failures disables it.
+type failureLockout struct {
+	Scope    string
+	Failures int
+	Window   time.Duration
+	Duration time.Duration
+	Err      error
+}
+
+func (s *TokenService) loginLockout() failureLockout {
+	return failureLockout{
+		Scope:    lockoutScope,
+		Failures: s.cfg.LockoutFailures,
+		Window:   s.cfg.LockoutWindow,
+		Duration: s.cfg.LockoutDuration,
+		Err:      pkg.ErrAccountLocked,
+	}
+}
+
+func (s *TokenService) checkLock(
+	ctx context.Context,
+	lockout failureLockout,
+	key string,
+) error {
+	if lockout.Failures <= 0 {
+		return nil
+	}
+	lockedUntil, err := s.rep.IRateLimit.LockedUntil(ctx, lockout.Scope, domain.HashSecret(key, s.cfg.EncKey))
+	if err != nil {
+		return err
+	}
+	retryAfter := time.Until(lockedUntil)
+	if retryAfter > 0 {
+		return &pkg.RateLimitError{Err: lockout.Err, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordFailure returns nil until the failure locking key out.
+func (s *TokenService) recordFailure(
+	ctx context.Context,
+	lockout failureLockout,
+	key string,
+) error {
+	if lockout.Failures <= 0 {
+		return nil
+	}
+	keyHash := domain.HashSecret(key, s.cfg.EncKey)
+	// the window holds the failures before the locking one
+	retryAfter, err := s.rep.IRateLimit.Hit(ctx, lockout.Scope, keyHash, lockout.Failures-1, lockout.Window)
+	if err != nil || retryAfter == 0 {
+		return err
+	}
+	err = s.rep.IRateLimit.Lock(ctx, lockout.Scope, keyHash, time.Now().UTC().Add(lockout.Duration))
+	if err != nil {
+		return err
+	}
+	err = s.rep.IRateLimit.Reset(ctx, lockout.Scope, keyHash)
+	if err != nil {
+		return err
+	}
+	domain.LoggerFromContext(ctx).Warn(domain.LogKeyword+": Locked", "scope", lockout.Scope)
+	return &pkg.RateLimitError{Err: lockout.Err, RetryAfter: lockout.Duration}
+}
+
+func (s *TokenService) resetFailures(
+	ctx context.Context,
+	lockout failureLockout,
+	key string,
+) error {
+	if lockout.Failures <= 0 {
+		return nil
+	}
+	return s.rep.IRateLimit.Reset(ctx, lockout.Scope, domain.HashSecret(key, s.cfg.EncKey))
+}
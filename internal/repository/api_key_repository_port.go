@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+type IAPIKey interface {
+	Add(
+		ctx context.Context,
+		dto domain.APIKeyDTO,
+	) error
+
+	GetById(
+		ctx context.Context,
+		id domain.APIKeyID,
+	) (*domain.APIKeyDTO, error)
+
+	FindByAuthID(
+		ctx context.Context,
+		id domain.AuthID,
+	) ([]domain.APIKeyDTO, error)
+
+	Delete(
+		ctx context.Context,
+		dto domain.APIKeyDTO,
+	) error
+}
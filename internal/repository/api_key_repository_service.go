@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+// apiKeyKey is the hash holding every API key by key ID, keys may never
+// expire so they are kept in a hash like the client registrations.
+const apiKeyKey = "aki"
+
+type APIKeyService struct {
+	adaptor *RedisAdaptor
+}
+
+func NewAPIKeyService(
+	adaptor *RedisAdaptor,
+) *APIKeyService {
+	return &APIKeyService{
+		adaptor: adaptor,
+	}
+}
+
+// authKeyIndex lists the key IDs owned by an auth ID.
+func (s *APIKeyService) authKeyIndex(
+	id domain.AuthID,
+) string {
+	return fmt.Sprintf("auk:%s", id)
+}
+
+func (s *APIKeyService) Add(
+	ctx context.Context,
+	dto domain.APIKeyDTO,
+) error {
+	val, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	indexKey := s.authKeyIndex(dto.AuthID)
+	return s.adaptor.ExecuteTransaction(
+		ctx, []string{apiKeyKey, indexKey}, func(pipe redis.Pipeliner) error {
+			err := s.adaptor.HSet(ctx, apiKeyKey, map[string]string{string(dto.ID): string(val)}, pipe)
+			if err != nil {
+				return err
+			}
+			return s.adaptor.HSet(ctx, indexKey, map[string]string{string(dto.ID): dto.Name}, pipe)
+		},
+	)
+}
+
+func (s *APIKeyService) GetById(
+	ctx context.Context,
+	id domain.APIKeyID,
+) (*domain.APIKeyDTO, error) {
+	val, err := s.adaptor.HGet(ctx, apiKeyKey, string(id))
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, nil
+	}
+	dto := domain.APIKeyDTO{}
+	err = json.Unmarshal(val, &dto)
+	if err != nil {
+		return nil, err
+	}
+	if dto.ID == "" {
+		return nil, nil
+	}
+	return &dto, nil
+}
+
+func (s *APIKeyService) FindByAuthID(
+	ctx context.Context,
+	id domain.AuthID,
+) ([]domain.APIKeyDTO, error) {
+	index, err := s.adaptor.HGetAll(ctx, s.authKeyIndex(id))
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]domain.APIKeyDTO, 0, len(index))
+	if len(index) == 0 {
+		return dtos, nil
+	}
+	fields := make([]string, 0, len(index))
+	for field := range index {
+		fields = append(fields, field)
+	}
+	values, err := s.adaptor.HMGet(ctx, apiKeyKey, fields)
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		dto := domain.APIKeyDTO{}
+		err = json.Unmarshal([]byte(str), &dto)
+		if err != nil {
+			return nil, err
+		}
+		dtos = append(dtos, dto)
+	}
+	return dtos, nil
+}
+
+func (s *APIKeyService) Delete(
+	ctx context.Context,
+	dto domain.APIKeyDTO,
+) error {
+	indexKey := s.authKeyIndex(dto.AuthID)
+	return s.adaptor.ExecuteTransaction(
+		ctx, []string{apiKeyKey, indexKey}, func(pipe redis.Pipeliner) error {
+			_, err := s.adaptor.HDelete(ctx, apiKeyKey, []string{string(dto.ID)}, pipe)
+			if err != nil {
+				return err
+			}
+			_, err = s.adaptor.HDelete(ctx, indexKey, []string{string(dto.ID)}, pipe)
+			return err
+		},
+	)
+}
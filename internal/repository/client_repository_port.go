@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+type IClient interface {
+	Save(
+		ctx context.Context,
+		dto domain.ClientDTO,
+	) error
+
+	GetById(
+		ctx context.Context,
+		id domain.ClientID,
+	) (*domain.ClientDTO, error)
+
+	Delete(
+		ctx context.Context,
+		id domain.ClientID,
+	) (bool, error)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+// clientKey is the hash holding every client registration by client ID.
+// Registrations do not expire, so a hash is used instead of plain keys.
+const clientKey = "cli"
+
+type ClientService struct {
+	adaptor *RedisAdaptor
+}
+
+func NewClientService(
+	adaptor *RedisAdaptor,
+) *ClientService {
+	return &ClientService{
+		adaptor: adaptor,
+	}
+}
+
+func (s *ClientService) Save(
+	ctx context.Context,
+	dto domain.ClientDTO,
+) error {
+	val, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	return s.adaptor.HSet(ctx, clientKey, map[string]string{string(dto.ID): string(val)}, nil)
+}
+
+func (s *ClientService) GetById(
+	ctx context.Context,
+	id domain.ClientID,
+) (*domain.ClientDTO, error) {
+	val, err := s.adaptor.HGet(ctx, clientKey, string(id))
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, nil
+	}
+	dto := domain.ClientDTO{}
+	err = json.Unmarshal(val, &dto)
+	if err != nil {
+		return nil, err
+	}
+	if dto.ID == "" {
+		return nil, nil
+	}
+	return &dto, nil
+}
+
+func (s *ClientService) Delete(
+	ctx context.Context,
+	id domain.ClientID,
+) (bool, error) {
+	val, err := s.adaptor.HDelete(ctx, clientKey, []string{string(id)}, nil)
+	if err != nil {
+		return false, err
+	}
+	return val > 0, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+// ICode stores short lived single use codes by the hash of the code.
+type ICode interface {
+	AddAuthorizationCode(
+		ctx context.Context,
+		codeHash string,
+		dto domain.AuthorizationCodeDTO,
+	) error
+
+	TakeAuthorizationCode(
+		ctx context.Context,
+		codeHash string,
+	) (*domain.AuthorizationCodeDTO, error)
+
+	AddDeviceCode(
+		ctx context.Context,
+		codeHash string,
+		dto domain.DeviceCodeDTO,
+	) error
+
+	GetDeviceCodeByUserCode(
+		ctx context.Context,
+		userCode string,
+	) (string, *domain.DeviceCodeDTO, error)
+
+	SaveDeviceCode(
+		ctx context.Context,
+		codeHash string,
+		dto domain.DeviceCodeDTO,
+	) error
+
+	PollDeviceCode(
+		ctx context.Context,
+		codeHash string,
+		clientID domain.ClientID,
+	) (*domain.DeviceCodeDTO, domain.DevicePoll, error)
+
+	AddOTP(
+		ctx context.Context,
+		challengeHash string,
+		dto domain.OTPDTO,
+	) error
+
+	TakeOTP(
+		ctx context.Context,
+		challengeHash string,
+	) (*domain.OTPDTO, error)
+}
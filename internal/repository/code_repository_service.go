@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+type CodeService struct {
+	adaptor *RedisAdaptor
+}
+
+func NewCodeService(
+	adaptor *RedisAdaptor,
+) *CodeService {
+	return &CodeService{
+		adaptor: adaptor,
+	}
+}
+
+func (s *CodeService) buildAuthorizationCodeKey(
+	codeHash string,
+) string {
+	return fmt.Sprintf("acd:%s", codeHash)
+}
+
+func (s *CodeService) AddAuthorizationCode(
+	ctx context.Context,
+	codeHash string,
+	dto domain.AuthorizationCodeDTO,
+) error {
+	val, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	return s.adaptor.Set(ctx, s.buildAuthorizationCodeKey(codeHash), val, time.Until(dto.ExpiresAt), nil)
+}
+
+// TakeAuthorizationCode returns and deletes the code, so a replayed code
+// finds nothing.
+func (s *CodeService) TakeAuthorizationCode(
+	ctx context.Context,
+	codeHash string,
+) (*domain.AuthorizationCodeDTO, error) {
+	val, err := s.adaptor.GetDelete(ctx, s.buildAuthorizationCodeKey(codeHash))
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, nil
+	}
+	dto := domain.AuthorizationCodeDTO{}
+	err = json.Unmarshal(val, &dto)
+	if err != nil {
+		return nil, err
+	}
+	return &dto, nil
+}
+
+func (s *CodeService) buildDeviceCodeKey(
+	codeHash string,
+) string {
+	return fmt.Sprintf("dvc:%s", codeHash)
+}
+
+// buildUserCodeKey points a normalized user code to its device code hash.
+func (s *CodeService) buildUserCodeKey(
+	userCode string,
+) string {
+	return fmt.Sprintf("usc:%s", domain.NormalizeUserCode(userCode))
+}
+
+func (s *CodeService) AddDeviceCode(
+	ctx context.Context,
+	codeHash string,
+	dto domain.DeviceCodeDTO,
+) error {
+	val, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	expireIn := time.Until(dto.ExpiresAt)
+	return s.adaptor.ExecuteTransaction(
+		ctx, []string{s.buildDeviceCodeKey(codeHash)}, func(pipe redis.Pipeliner) error {
+			err := s.adaptor.Set(ctx, s.buildDeviceCodeKey(codeHash), val, expireIn, pipe)
+			if err != nil {
+				return err
+			}
+			return s.adaptor.Set(ctx, s.buildUserCodeKey(dto.UserCode), codeHash, expireIn, pipe)
+		},
+	)
+}
+
+func (s *CodeService) GetDeviceCodeByUserCode(
+	ctx context.Context,
+	userCode string,
+) (string, *domain.DeviceCodeDTO, error) {
+	codeHash, err := s.adaptor.Get(ctx, s.buildUserCodeKey(userCode))
+	if err != nil || codeHash == nil {
+		return "", nil, err
+	}
+	val, err := s.adaptor.Get(ctx, s.buildDeviceCodeKey(string(codeHash)))
+	if err != nil || val == nil {
+		return "", nil, err
+	}
+	dto := domain.DeviceCodeDTO{}
+	err = json.Unmarshal(val, &dto)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(codeHash), &dto, nil
+}
+
+func (s *CodeService) SaveDeviceCode(
+	ctx context.Context,
+	codeHash string,
+	dto domain.DeviceCodeDTO,
+) error {
+	val, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	return s.adaptor.Set(ctx, s.buildDeviceCodeKey(codeHash), val, time.Until(dto.ExpiresAt), nil)
+}
+
+// PollDeviceCode applies DeviceCodeDTO.Poll atomically so concurrent polls
+// cannot both redeem an approved code. Settled codes are deleted, codes of
+// another client than clientID are left untouched.
+func (s *CodeService) PollDeviceCode(
+	ctx context.Context,
+	codeHash string,
+	clientID domain.ClientID,
+) (*domain.DeviceCodeDTO, domain.DevicePoll, error) {
+	deviceKey := s.buildDeviceCodeKey(codeHash)
+	var dto *domain.DeviceCodeDTO
+	var poll domain.DevicePoll
+	err := s.adaptor.ExecuteReadTransaction(
+		ctx,
+		[]string{deviceKey},
+		func(tx *redis.Tx) error {
+			val, err := s.adaptor.TxGet(ctx, tx, deviceKey)
+			if err != nil {
+				return err
+			}
+			dto, poll = nil, domain.DevicePollNotFound
+			if val == nil {
+				return nil
+			}
+			code := &domain.DeviceCodeDTO{}
+			err = json.Unmarshal(val, code)
+			if err != nil {
+				return err
+			}
+			if code.ClientID != clientID {
+				return nil
+			}
+			dto = code
+			poll = dto.Poll(time.Now().UTC())
+			return nil
+		},
+		func(pipe redis.Pipeliner) error {
+			if dto == nil {
+				return nil
+			}
+			expireIn := time.Until(dto.ExpiresAt)
+			if poll == domain.DevicePollPending || poll == domain.DevicePollSlowDown {
+				if expireIn > 0 {
+					val, err := json.Marshal(dto)
+					if err != nil {
+						return err
+					}
+					return s.adaptor.Set(ctx, deviceKey, val, expireIn, pipe)
+				}
+				poll = domain.DevicePollExpired
+			}
+			_, err := s.adaptor.DeleteMultiple(ctx, []string{deviceKey, s.buildUserCodeKey(dto.UserCode)}, pipe)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, domain.DevicePollNotFound, err
+	}
+	return dto, poll, nil
+}
+
+func (s *CodeService) buildOTPKey(
+	challengeHash string,
+) string {
+	return fmt.Sprintf("otp:%s", challengeHash)
+}
+
+func (s *CodeService) AddOTP(
+	ctx context.Context,
+	challengeHash string,
+	dto domain.OTPDTO,
+) error {
+	val, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	return s.adaptor.Set(ctx, s.buildOTPKey(challengeHash), val, time.Until(dto.ExpiresAt), nil)
+}
+
+// TakeOTP returns and deletes the challenge, a failed attempt stores it again.
+func (s *CodeService) TakeOTP(
+	ctx context.Context,
+	challengeHash string,
+) (*domain.OTPDTO, error) {
+	val, err := s.adaptor.GetDelete(ctx, s.buildOTPKey(challengeHash))
+	if err != nil || val == nil {
+		return nil, err
+	}
+	dto := domain.OTPDTO{}
+	err = json.Unmarshal(val, &dto)
+	if err != nil {
+		return nil, err
+	}
+	return &dto, nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+func newTestAdaptor(
+	t *testing.T,
+) *RedisAdaptor {
+	server := miniredis.RunT(t)
+	return NewRedisAdaptor(redis.NewClient(&redis.Options{Addr: server.Addr()}), nil)
+}
+
+func TestTakeAuthorizationCodeOnce(t *testing.T) {
+	ctx := context.Background()
+	service := NewCodeService(newTestAdaptor(t))
+	err := service.AddAuthorizationCode(ctx, "hash", domain.AuthorizationCodeDTO{
+		ClientID:  "client",
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := service.TakeAuthorizationCode(ctx, "hash")
+	if err != nil || code == nil || code.ClientID != "client" {
+		t.Fatalf("TakeAuthorizationCode = %v, %v", code, err)
+	}
+	code, err = service.TakeAuthorizationCode(ctx, "hash")
+	if err != nil || code != nil {
+		t.Fatalf("second TakeAuthorizationCode = %v, %v, want nil", code, err)
+	}
+}
+
+func TestPollDeviceCodeOfOtherClient(t *testing.T) {
+	ctx := context.Background()
+	service := NewCodeService(newTestAdaptor(t))
+	err := service.AddDeviceCode(ctx, "hash", domain.DeviceCodeDTO{
+		ClientID:  "client",
+		UserCode:  "BCDFGHJK",
+		Status:    domain.DeviceCodeApproved,
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dto, poll, err := service.PollDeviceCode(ctx, "unknown", "client")
+	if err != nil || dto != nil || poll != domain.DevicePollNotFound {
+		t.Fatalf("unknown code = %v, %v, %v, want not found", dto, poll, err)
+	}
+	dto, poll, err = service.PollDeviceCode(ctx, "hash", "other")
+	if err != nil || dto != nil || poll != domain.DevicePollNotFound {
+		t.Fatalf("other client = %v, %v, %v, want not found", dto, poll, err)
+	}
+	dto, poll, err = service.PollDeviceCode(ctx, "hash", "client")
+	if err != nil || dto == nil || poll != domain.DevicePollApproved {
+		t.Fatalf("owning client = %v, %v, %v, want approved", dto, poll, err)
+	}
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+// IMFA stores the TOTP second factor of auth IDs and the logins waiting for it.
+type IMFA interface {
+	GetTOTP(
+		ctx context.Context,
+		authID domain.AuthID,
+	) (*domain.TOTPDTO, error)
+
+	SaveTOTP(
+		ctx context.Context,
+		dto domain.TOTPDTO,
+	) error
+
+	DeleteTOTP(
+		ctx context.Context,
+		authID domain.AuthID,
+	) (bool, error)
+
+	UseTOTPCounter(
+		ctx context.Context,
+		authID domain.AuthID,
+		counter int64,
+	) (bool, error)
+
+	UseRecoveryCode(
+		ctx context.Context,
+		authID domain.AuthID,
+		codeHash string,
+	) (bool, error)
+
+	SetRecoveryCodes(
+		ctx context.Context,
+		authID domain.AuthID,
+		codeHashes []string,
+	) (bool, error)
+
+	AddMFAPending(
+		ctx context.Context,
+		tokenHash string,
+		dto domain.MFAPendingDTO,
+	) error
+
+	TakeMFAPending(
+		ctx context.Context,
+		tokenHash string,
+	) (*domain.MFAPendingDTO, error)
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+// totpField is the field of the TOTP factor in the MFA hash of an auth ID.
+const totpField = "totp"
+
+type MFAService struct {
+	adaptor *RedisAdaptor
+}
+
+func NewMFAService(
+	adaptor *RedisAdaptor,
+) *MFAService {
+	return &MFAService{
+		adaptor: adaptor,
+	}
+}
+
+func (s *MFAService) buildKey(
+	authID domain.AuthID,
+) string {
+	return fmt.Sprintf("tfa:%s", authID)
+}
+
+func (s *MFAService) buildPendingKey(
+	tokenHash string,
+) string {
+	return fmt.Sprintf("mfa:%s", tokenHash)
+}
+
+func (s *MFAService) GetTOTP(
+	ctx context.Context,
+	authID domain.AuthID,
+) (*domain.TOTPDTO, error) {
+	val, err := s.adaptor.HGet(ctx, s.buildKey(authID), totpField)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	dto := domain.TOTPDTO{}
+	err = json.Unmarshal(val, &dto)
+	if err != nil {
+		return nil, err
+	}
+	return &dto, nil
+}
+
+func (s *MFAService) SaveTOTP(
+	ctx context.Context,
+	dto domain.TOTPDTO,
+) error {
+	val, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	return s.adaptor.HSet(ctx, s.buildKey(dto.AuthID), map[string]string{totpField: string(val)}, nil)
+}
+
+func (s *MFAService) DeleteTOTP(
+	ctx context.Context,
+	authID domain.AuthID,
+) (bool, error) {
+	count, err := s.adaptor.HDelete(ctx, s.buildKey(authID), []string{totpField}, nil)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// UseTOTPCounter records counter as used, it returns false when the same or
+// a later time step was used before so a code cannot be replayed.
+func (s *MFAService) UseTOTPCounter(
+	ctx context.Context,
+	authID domain.AuthID,
+	counter int64,
+) (bool, error) {
+	return s.update(ctx, authID, func(dto *domain.TOTPDTO) bool {
+		return dto.UseCounter(counter)
+	})
+}
+
+// UseRecoveryCode removes codeHash from the unused recovery codes, it returns
+// false when the code is unknown or was used before.
+func (s *MFAService) UseRecoveryCode(
+	ctx context.Context,
+	authID domain.AuthID,
+	codeHash string,
+) (bool, error) {
+	return s.update(ctx, authID, func(dto *domain.TOTPDTO) bool {
+		index := slices.Index(dto.RecoveryCodes, codeHash)
+		if index < 0 {
+			return false
+		}
+		dto.RecoveryCodes = slices.Delete(dto.RecoveryCodes, index, index+1)
+		return true
+	})
+}
+
+// SetRecoveryCodes replaces the recovery codes of a confirmed factor.
+func (s *MFAService) SetRecoveryCodes(
+	ctx context.Context,
+	authID domain.AuthID,
+	codeHashes []string,
+) (bool, error) {
+	return s.update(ctx, authID, func(dto *domain.TOTPDTO) bool {
+		if !dto.Confirmed {
+			return false
+		}
+		dto.RecoveryCodes = codeHashes
+		return true
+	})
+}
+
+// update applies apply to the TOTP factor of authID atomically and saves it
+// when apply returns true.
+func (s *MFAService) update(
+	ctx context.Context,
+	authID domain.AuthID,
+	apply func(dto *domain.TOTPDTO) bool,
+) (bool, error) {
+	key := s.buildKey(authID)
+	var dto *domain.TOTPDTO
+	var applied bool
+	err := s.adaptor.ExecuteReadTransaction(
+		ctx,
+		[]string{key},
+		func(tx *redis.Tx) error {
+			fields, err := s.adaptor.TxHGetAll(ctx, tx, key)
+			if err != nil {
+				return err
+			}
+			dto, applied = nil, false
+			val, found := fields[totpField]
+			if !found {
+				return nil
+			}
+			dto = &domain.TOTPDTO{}
+			err = json.Unmarshal([]byte(val), dto)
+			if err != nil {
+				return err
+			}
+			applied = apply(dto)
+			return nil
+		},
+		func(pipe redis.Pipeliner) error {
+			if !applied {
+				return nil
+			}
+			val, err := json.Marshal(dto)
+			if err != nil {
+				return err
+			}
+			return s.adaptor.HSet(ctx, key, map[string]string{totpField: string(val)}, pipe)
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return applied, nil
+}
+
+func (s *MFAService) AddMFAPending(
+	ctx context.Context,
+	tokenHash string,
+	dto domain.MFAPendingDTO,
+) error {
+	val, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	return s.adaptor.Set(ctx, s.buildPendingKey(tokenHash), val, time.Until(dto.ExpiresAt), nil)
+}
+
+// TakeMFAPending returns and deletes the pending login, so concurrent
+// attempts cannot verify the same login twice.
+func (s *MFAService) TakeMFAPending(
+	ctx context.Context,
+	tokenHash string,
+) (*domain.MFAPendingDTO, error) {
+	val, err := s.adaptor.GetDelete(ctx, s.buildPendingKey(tokenHash))
+	if err != nil || val == nil {
+		return nil, err
+	}
+	dto := domain.MFAPendingDTO{}
+	err = json.Unmarshal(val, &dto)
+	if err != nil {
+		return nil, err
+	}
+	return &dto, nil
+}
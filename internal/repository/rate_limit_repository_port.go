@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// IRateLimit keeps the sliding windows of rate limits and the lockouts of
+// keys in a scope, keys are hashed by the caller.
+type IRateLimit interface {
+	// Hit records a call of keyHash in scope unless limit calls were recorded
+	// within window, it then returns the wait until the oldest leaves it.
+	Hit(
+		ctx context.Context,
+		scope string,
+		keyHash string,
+		limit int,
+		window time.Duration,
+	) (time.Duration, error)
+
+	Reset(
+		ctx context.Context,
+		scope string,
+		keyHash string,
+	) error
+
+	Lock(
+		ctx context.Context,
+		scope string,
+		keyHash string,
+		until time.Time,
+	) error
+
+	// LockedUntil returns the zero time when keyHash is not locked in scope.
+	LockedUntil(
+		ctx context.Context,
+		scope string,
+		keyHash string,
+	) (time.Time, error)
+}
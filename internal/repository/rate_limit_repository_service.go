@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+// slidingWindowScript keeps the call times of a key in a sorted set. A call is
+// only recorded while fewer than the limit remain within the window, so
+// rejected calls do not extend it. Times are in milliseconds.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+if redis.call('ZCARD', KEYS[1]) < tonumber(ARGV[3]) then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	redis.call('PEXPIRE', KEYS[1], window)
+	return 0
+end
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if #oldest == 0 then
+	return window
+end
+return tonumber(oldest[2]) + window - now
+`)
+
+type RateLimitService struct {
+	adaptor *RedisAdaptor
+}
+
+func NewRateLimitService(
+	adaptor *RedisAdaptor,
+) *RateLimitService {
+	return &RateLimitService{
+		adaptor: adaptor,
+	}
+}
+
+func (s *RateLimitService) buildKey(
+	scope string,
+	keyHash string,
+) string {
+	return fmt.Sprintf("rlw:%s:%s", scope, keyHash)
+}
+
+func (s *RateLimitService) buildLockKey(
+	scope string,
+	keyHash string,
+) string {
+	return fmt.Sprintf("rll:%s:%s", scope, keyHash)
+}
+
+func (s *RateLimitService) Hit(
+	ctx context.Context,
+	scope string,
+	keyHash string,
+	limit int,
+	window time.Duration,
+) (time.Duration, error) {
+	// the random suffix keeps calls of the same millisecond apart
+	member, err := domain.RandomString(8)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UnixMilli()
+	val, err := s.adaptor.RunScript(
+		ctx, slidingWindowScript,
+		[]string{s.buildKey(scope, keyHash)},
+		now, window.Milliseconds(), limit, fmt.Sprintf("%d:%s", now, member),
+	)
+	if err != nil {
+		return 0, err
+	}
+	retryAfter, ok := val.(int64)
+	if !ok {
+		return 0, errors.New("UnexpectedScriptReply")
+	}
+	return time.Duration(retryAfter) * time.Millisecond, nil
+}
+
+func (s *RateLimitService) Reset(
+	ctx context.Context,
+	scope string,
+	keyHash string,
+) error {
+	_, err := s.adaptor.Delete(ctx, s.buildKey(scope, keyHash))
+	return err
+}
+
+func (s *RateLimitService) Lock(
+	ctx context.Context,
+	scope string,
+	keyHash string,
+	until time.Time,
+) error {
+	return s.adaptor.Set(ctx, s.buildLockKey(scope, keyHash), until.Format(time.RFC3339), time.Until(until), nil)
+}
+
+func (s *RateLimitService) LockedUntil(
+	ctx context.Context,
+	scope string,
+	keyHash string,
+) (time.Time, error) {
+	val, err := s.adaptor.Get(ctx, s.buildLockKey(scope, keyHash))
+	if err != nil || val == nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, string(val))
+}
@@ -7,17 +7,26 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/c0dev0yager/goauth/internal/domain"
 )
 
+const maxTransactionAttempts = 3
+
 type RedisAdaptor struct {
 	redisClient *redis.Client
 }
 
 func NewRedisAdaptor(
 	redisClient *redis.Client,
+	tracer trace.Tracer,
 ) *RedisAdaptor {
+	if tracer != nil {
+		// hooks are added on a copy so the caller's client stays uninstrumented
+		redisClient = redisClient.WithContext(redisClient.Context())
+		redisClient.AddHook(&tracingHook{tracer: tracer})
+	}
 	return &RedisAdaptor{
 		redisClient: redisClient,
 	}
@@ -67,6 +76,22 @@ func (ra *RedisAdaptor) Get(
 	return nil, err
 }
 
+// GetDelete reads and removes key at once, used for single use values.
+func (ra *RedisAdaptor) GetDelete(
+	ctx context.Context,
+	key string,
+) ([]byte, error) {
+	redisKey := ra.buildKey(key)
+	val, err := ra.redisClient.GetDel(ctx, redisKey).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []byte(val), nil
+}
+
 func (ra *RedisAdaptor) GetMultiple(
 	ctx context.Context,
 	keys []string,
@@ -104,11 +129,16 @@ func (ra *RedisAdaptor) Delete(
 func (ra *RedisAdaptor) DeleteMultiple(
 	ctx context.Context,
 	keys []string,
+	pipe redis.Pipeliner,
 ) (int64, error) {
 	newKeys := make([]string, len(keys))
 	for index, key := range keys {
 		newKeys[index] = ra.buildKey(key)
 	}
+	if pipe != nil {
+		pipe.Del(ctx, newKeys...)
+		return 0, nil
+	}
 	val, err := ra.redisClient.Del(ctx, newKeys...).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -130,7 +160,7 @@ func (ra *RedisAdaptor) HSet(
 	if pipe != nil {
 		_, err = pipe.HSet(ctx, redisKey, value).Result()
 	} else {
-		_, err = ra.redisClient.HSet(ctx, hashKey, value).Result()
+		_, err = ra.redisClient.HSet(ctx, redisKey, value).Result()
 	}
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -192,8 +222,13 @@ func (ra *RedisAdaptor) HDelete(
 	ctx context.Context,
 	hashKey string,
 	field []string,
+	pipe redis.Pipeliner,
 ) (int64, error) {
 	redisKey := ra.buildKey(hashKey)
+	if pipe != nil {
+		pipe.HDel(ctx, redisKey, field...)
+		return 0, nil
+	}
 	count, err := ra.redisClient.HDel(ctx, redisKey, field...).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
@@ -226,23 +261,97 @@ func (ra *RedisAdaptor) Expire(
 	return nil
 }
 
+// RunScript runs script atomically, keys are prefixed like every other key.
+func (ra *RedisAdaptor) RunScript(
+	ctx context.Context,
+	script *redis.Script,
+	keys []string,
+	args ...interface{},
+) (interface{}, error) {
+	redisKeys := make([]string, len(keys))
+	for index, key := range keys {
+		redisKeys[index] = ra.buildKey(key)
+	}
+	return script.Run(ctx, ra.redisClient, redisKeys, args...).Result()
+}
+
+func (ra *RedisAdaptor) TxGet(
+	ctx context.Context,
+	tx *redis.Tx,
+	key string,
+) ([]byte, error) {
+	redisKey := ra.buildKey(key)
+	val, err := tx.Get(ctx, redisKey).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []byte(val), nil
+}
+
+func (ra *RedisAdaptor) TxHGetAll(
+	ctx context.Context,
+	tx *redis.Tx,
+	hashKey string,
+) (map[string]string, error) {
+	redisKey := ra.buildKey(hashKey)
+	val, err := tx.HGetAll(ctx, redisKey).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return val, nil
+}
+
 func (ra *RedisAdaptor) ExecuteTransaction(
 	ctx context.Context,
 	keys []string,
 	pipelineFunc func(pipe redis.Pipeliner) error,
 ) error {
-	// Watch the keys before starting the transaction
-	err := ra.redisClient.Watch(
-		ctx, func(tx *redis.Tx) error {
-			// Execute the provided pipeline function inside the transaction
-			_, err := tx.Pipelined(
-				ctx, func(pipe redis.Pipeliner) error {
-					return pipelineFunc(pipe)
-				},
-			)
-			return err
-		}, keys...,
-	)
+	return ra.ExecuteReadTransaction(ctx, keys, nil, pipelineFunc)
+}
+
+// ExecuteReadTransaction runs readFunc against the watched keys and then
+// pipelineFunc inside MULTI/EXEC, retrying when a watched key changes in between.
+func (ra *RedisAdaptor) ExecuteReadTransaction(
+	ctx context.Context,
+	keys []string,
+	readFunc func(tx *redis.Tx) error,
+	pipelineFunc func(pipe redis.Pipeliner) error,
+) error {
+	redisKeys := make([]string, len(keys))
+	for index, key := range keys {
+		redisKeys[index] = ra.buildKey(key)
+	}
+
+	var err error
+	for attempt := 0; attempt < maxTransactionAttempts; attempt++ {
+		// Watch the keys before starting the transaction
+		err = ra.redisClient.Watch(
+			ctx, func(tx *redis.Tx) error {
+				if readFunc != nil {
+					err := readFunc(tx)
+					if err != nil {
+						return err
+					}
+				}
+				// Execute the provided pipeline function inside the transaction
+				_, err := tx.TxPipelined(
+					ctx, func(pipe redis.Pipeliner) error {
+						return pipelineFunc(pipe)
+					},
+				)
+				return err
+			}, redisKeys...,
+		)
+		if !errors.Is(err, redis.TxFailedErr) {
+			break
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -2,19 +2,48 @@ package repository
 
 import (
 	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/c0dev0yager/goauth/metrics"
 )
 
 type TokenRepository struct {
-	IToken IToken
+	IToken     IToken
+	IClient    IClient
+	IAPIKey    IAPIKey
+	ICode      ICode
+	IMFA       IMFA
+	IRateLimit IRateLimit
 }
 
 func (repository *TokenRepository) Build(
 	redisClient *redis.Client,
+	recorder metrics.Recorder,
+	tracer trace.Tracer,
 ) {
 	redisAdaptor := NewRedisAdaptor(
 		redisClient,
+		tracer,
+	)
+	repository.IToken = NewMeteredToken(
+		NewTokenService(
+			redisAdaptor,
+		),
+		recorder,
+	)
+	repository.IClient = NewClientService(
+		redisAdaptor,
+	)
+	repository.IAPIKey = NewAPIKeyService(
+		redisAdaptor,
+	)
+	repository.ICode = NewCodeService(
+		redisAdaptor,
+	)
+	repository.IMFA = NewMFAService(
+		redisAdaptor,
 	)
-	repository.IToken = NewTokenService(
+	repository.IRateLimit = NewRateLimitService(
 		redisAdaptor,
 	)
 }
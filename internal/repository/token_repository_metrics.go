@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/metrics"
+)
+
+// MeteredToken records the latency of every IToken call.
+type MeteredToken struct {
+	next     IToken
+	recorder metrics.Recorder
+}
+
+func NewMeteredToken(
+	next IToken,
+	recorder metrics.Recorder,
+) *MeteredToken {
+	return &MeteredToken{
+		next:     next,
+		recorder: recorder,
+	}
+}
+
+func (m *MeteredToken) observe(
+	operation string,
+	start time.Time,
+	err error,
+) {
+	m.recorder.ObserveRepository(operation, time.Since(start), err)
+}
+
+func (m *MeteredToken) Add(
+	ctx context.Context,
+	dto domain.TokenDTO,
+	policy domain.SessionPolicy,
+) (*domain.TokenDTO, []domain.TokenDTO, error) {
+	start := time.Now()
+	res, revoked, err := m.next.Add(ctx, dto, policy)
+	m.observe("Add", start, err)
+	return res, revoked, err
+}
+
+func (m *MeteredToken) Touch(
+	ctx context.Context,
+	dto domain.TokenDTO,
+) error {
+	start := time.Now()
+	err := m.next.Touch(ctx, dto)
+	m.observe("Touch", start, err)
+	return err
+}
+
+func (m *MeteredToken) GetById(
+	ctx context.Context,
+	id domain.TokenID,
+) (*domain.TokenDTO, error) {
+	start := time.Now()
+	res, err := m.next.GetById(ctx, id)
+	m.observe("GetById", start, err)
+	return res, err
+}
+
+func (m *MeteredToken) GetByAuthID(
+	ctx context.Context,
+	id domain.AuthID,
+	field string,
+) (*domain.TokenDTO, error) {
+	start := time.Now()
+	res, err := m.next.GetByAuthID(ctx, id, field)
+	m.observe("GetByAuthID", start, err)
+	return res, err
+}
+
+func (m *MeteredToken) FindByAuthID(
+	ctx context.Context,
+	id domain.AuthID,
+) ([]domain.TokenDTO, error) {
+	start := time.Now()
+	res, err := m.next.FindByAuthID(ctx, id)
+	m.observe("FindByAuthID", start, err)
+	return res, err
+}
+
+func (m *MeteredToken) Delete(
+	ctx context.Context,
+	id domain.TokenID,
+) (bool, error) {
+	start := time.Now()
+	res, err := m.next.Delete(ctx, id)
+	m.observe("Delete", start, err)
+	return res, err
+}
+
+func (m *MeteredToken) DeleteAuth(
+	ctx context.Context,
+	id domain.AuthID,
+) (bool, error) {
+	start := time.Now()
+	res, err := m.next.DeleteAuth(ctx, id)
+	m.observe("DeleteAuth", start, err)
+	return res, err
+}
+
+func (m *MeteredToken) MultiDelete(
+	ctx context.Context,
+	ids []domain.TokenID,
+) (int64, error) {
+	start := time.Now()
+	res, err := m.next.MultiDelete(ctx, ids)
+	m.observe("MultiDelete", start, err)
+	return res, err
+}
+
+func (m *MeteredToken) DeleteAuthFields(
+	ctx context.Context,
+	authId domain.AuthID,
+	fields []string,
+) (int64, error) {
+	start := time.Now()
+	res, err := m.next.DeleteAuthFields(ctx, authId, fields)
+	m.observe("DeleteAuthFields", start, err)
+	return res, err
+}
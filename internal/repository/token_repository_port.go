@@ -10,7 +10,13 @@ type IToken interface {
 	Add(
 		ctx context.Context,
 		dto domain.TokenDTO,
-	) (*domain.TokenDTO, error)
+		policy domain.SessionPolicy,
+	) (*domain.TokenDTO, []domain.TokenDTO, error)
+
+	Touch(
+		ctx context.Context,
+		dto domain.TokenDTO,
+	) error
 
 	GetById(
 		ctx context.Context,
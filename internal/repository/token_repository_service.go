@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
 
 	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
 )
 
 type TokenService struct {
@@ -36,31 +39,83 @@ func (s *TokenService) buildAuthKey(
 	return fmt.Sprintf("aui:%s", id)
 }
 
+// buildExchangedKey holds the exchanged tokens of an auth ID apart from its
+// sessions, the key expires with the last of them.
+func (s *TokenService) buildExchangedKey(
+	id domain.AuthID,
+) string {
+	return fmt.Sprintf("axi:%s", id)
+}
+
+func (s *TokenService) sessionsKey(
+	dto domain.TokenDTO,
+) string {
+	if dto.Exchanged {
+		return s.buildExchangedKey(dto.AuthID)
+	}
+	return s.buildAuthKey(dto.AuthID)
+}
+
 func (s *TokenService) Add(
 	ctx context.Context,
 	dto domain.TokenDTO,
-) (*domain.TokenDTO, error) {
-	tid, err := uuid.NewUUID()
-	if err != nil {
-		return nil, err
+	policy domain.SessionPolicy,
+) (*domain.TokenDTO, []domain.TokenDTO, error) {
+	// opaque tokens arrive with the ID derived from the token
+	if dto.ID == "" {
+		tid, err := uuid.NewUUID()
+		if err != nil {
+			return nil, nil, err
+		}
+		dto.ID = domain.TokenID(tid.String())
 	}
-	dto.ID = domain.TokenID(tid.String())
 
 	atKey := s.buildKey(dto.ID)
 	atVal, err := json.Marshal(dto)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	atExpireIn := time.Duration(dto.ExpiresAt.Sub(dto.CreatedAt).Minutes()) * time.Minute
-	authKey := s.buildAuthKey(dto.AuthID)
+	authKey := s.sessionsKey(dto)
 	authVal := map[string]string{
 		dto.UniqueKey: string(atVal),
 	}
 
-	err = s.adaptor.ExecuteTransaction(
+	var evicted []domain.TokenDTO
+	var replaced *domain.TokenDTO
+	var expired []string
+	// Inactivity for 30 days leads to expire authKey
+	authExpireIn := 30 * 24 * time.Hour
+	err = s.adaptor.ExecuteReadTransaction(
 		ctx,
 		[]string{atKey, authKey},
+		func(tx *redis.Tx) error {
+			sessions, err := s.adaptor.TxHGetAll(ctx, tx, authKey)
+			if err != nil {
+				return err
+			}
+			replaced, err = s.findReplaced(sessions, dto)
+			if err != nil {
+				return err
+			}
+			expired, err = s.findExpiredUnrefreshable(sessions)
+			if err != nil {
+				return err
+			}
+			if dto.Exchanged {
+				authExpireIn, err = s.lastExpiry(sessions, dto)
+				if err != nil {
+					return err
+				}
+			}
+			evicted = nil
+			if replaced != nil || policy.Limit.Max <= 0 {
+				return nil
+			}
+			evicted, err = s.selectEvictions(sessions, policy.Limit, expired)
+			return err
+		},
 		func(pipe redis.Pipeliner) error {
 			err = s.adaptor.Set(ctx, atKey, atVal, atExpireIn, pipe)
 			if err != nil {
@@ -70,18 +125,220 @@ func (s *TokenService) Add(
 			if err != nil {
 				return err
 			}
-			// Inactivity for 30 days leads to expire authKey
-			err = s.adaptor.Expire(ctx, authKey, 30*24*time.Hour, pipe)
+			err = s.adaptor.Expire(ctx, authKey, authExpireIn, pipe)
+			if err != nil {
+				return err
+			}
+			err = s.revokeReplaced(ctx, replaced, policy.ReplacedOverlap, pipe)
+			if err != nil {
+				return err
+			}
+			if len(expired) > 0 {
+				_, err = s.adaptor.HDelete(ctx, authKey, expired, pipe)
+				if err != nil {
+					return err
+				}
+			}
+			if len(evicted) == 0 {
+				return nil
+			}
+			fields := make([]string, len(evicted))
+			keys := make([]string, len(evicted))
+			for i, session := range evicted {
+				fields[i] = session.UniqueKey
+				keys[i] = s.buildKey(session.ID)
+			}
+			_, err = s.adaptor.HDelete(ctx, authKey, fields, pipe)
+			if err != nil {
+				return err
+			}
+			_, err = s.adaptor.DeleteMultiple(ctx, keys, pipe)
 			if err != nil {
 				return err
 			}
 			return nil
 		},
 	)
+	if err != nil {
+		return nil, nil, err
+	}
+	revoked := evicted
+	if replaced != nil {
+		revoked = append(revoked, *replaced)
+	}
+	return &dto, revoked, nil
+}
+
+// findReplaced returns the session currently stored under the unique key of dto.
+func (s *TokenService) findReplaced(
+	sessions map[string]string,
+	dto domain.TokenDTO,
+) (*domain.TokenDTO, error) {
+	val, found := sessions[dto.UniqueKey]
+	if !found {
+		return nil, nil
+	}
+	replaced := domain.TokenDTO{}
+	err := json.Unmarshal([]byte(val), &replaced)
 	if err != nil {
 		return nil, err
 	}
-	return &dto, nil
+	if replaced.ID == "" {
+		return nil, nil
+	}
+	return &replaced, nil
+}
+
+// findExpiredUnrefreshable returns the unique keys of expired service and
+// exchanged sessions. They cannot be refreshed, so their entries are useless
+// once expired.
+func (s *TokenService) findExpiredUnrefreshable(
+	sessions map[string]string,
+) ([]string, error) {
+	now := time.Now().UTC()
+	expired := make([]string, 0)
+	for field, v := range sessions {
+		session := domain.TokenDTO{}
+		err := json.Unmarshal([]byte(v), &session)
+		if err != nil {
+			return nil, err
+		}
+		if !session.Refreshable() && session.ExpiresAt.Before(now) {
+			expired = append(expired, field)
+		}
+	}
+	return expired, nil
+}
+
+// lastExpiry returns the time until the last of sessions and dto expires.
+func (s *TokenService) lastExpiry(
+	sessions map[string]string,
+	dto domain.TokenDTO,
+) (time.Duration, error) {
+	last := dto.ExpiresAt
+	for _, v := range sessions {
+		session := domain.TokenDTO{}
+		err := json.Unmarshal([]byte(v), &session)
+		if err != nil {
+			return 0, err
+		}
+		if session.ExpiresAt.After(last) {
+			last = session.ExpiresAt
+		}
+	}
+	return time.Until(last), nil
+}
+
+// revokeReplaced deletes the access token of a replaced session, or shortens
+// its expiry to the overlap window when one is configured.
+func (s *TokenService) revokeReplaced(
+	ctx context.Context,
+	replaced *domain.TokenDTO,
+	overlap time.Duration,
+	pipe redis.Pipeliner,
+) error {
+	if replaced == nil {
+		return nil
+	}
+	key := s.buildKey(replaced.ID)
+	if overlap <= 0 {
+		_, err := s.adaptor.DeleteMultiple(ctx, []string{key}, pipe)
+		return err
+	}
+	if replaced.ExpiresAt.Before(time.Now().UTC().Add(overlap)) {
+		return nil
+	}
+	return s.adaptor.Expire(ctx, key, overlap, pipe)
+}
+
+// selectEvictions returns the sessions to revoke so that a new session fits in
+// limit. The expired entries are removed along with the new session and do
+// not count.
+func (s *TokenService) selectEvictions(
+	sessions map[string]string,
+	limit domain.SessionLimit,
+	expired []string,
+) ([]domain.TokenDTO, error) {
+	active := make([]domain.TokenDTO, 0, len(sessions))
+	for uniqueKey, v := range sessions {
+		if slices.Contains(expired, uniqueKey) {
+			continue
+		}
+		session := domain.TokenDTO{}
+		err := json.Unmarshal([]byte(v), &session)
+		if err != nil {
+			return nil, err
+		}
+		// exchanged tokens derive from another session rather than being one
+		if session.Exchanged || (limit.Role != "" && session.Role != limit.Role) {
+			continue
+		}
+		active = append(active, session)
+	}
+	if len(active) < limit.Max {
+		return nil, nil
+	}
+
+	switch limit.Policy {
+	case domain.SessionLimitEvictOldest:
+		sort.Slice(
+			active, func(i, j int) bool {
+				return active[i].StartedAt.Before(active[j].StartedAt)
+			},
+		)
+	case domain.SessionLimitEvictLeastRecentlyUsed:
+		sort.Slice(
+			active, func(i, j int) bool {
+				return active[i].LastUsedAt().Before(active[j].LastUsedAt())
+			},
+		)
+	default:
+		return nil, pkg.ErrSessionLimitReached
+	}
+	return active[:len(active)-limit.Max+1], nil
+}
+
+// Touch persists the activity fields of dto on both the access token and the
+// auth session, unless the unique key has meanwhile been reissued.
+func (s *TokenService) Touch(
+	ctx context.Context,
+	dto domain.TokenDTO,
+) error {
+	expireIn := dto.ExpiresAt.Sub(time.Now().UTC())
+	if expireIn <= 0 {
+		return nil
+	}
+
+	atKey := s.buildKey(dto.ID)
+	atVal, err := json.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	authKey := s.sessionsKey(dto)
+
+	var current *domain.TokenDTO
+	return s.adaptor.ExecuteReadTransaction(
+		ctx,
+		[]string{atKey, authKey},
+		func(tx *redis.Tx) error {
+			sessions, err := s.adaptor.TxHGetAll(ctx, tx, authKey)
+			if err != nil {
+				return err
+			}
+			current, err = s.findReplaced(sessions, dto)
+			return err
+		},
+		func(pipe redis.Pipeliner) error {
+			if current == nil || current.ID != dto.ID {
+				return nil
+			}
+			err = s.adaptor.Set(ctx, atKey, atVal, expireIn, pipe)
+			if err != nil {
+				return err
+			}
+			return s.adaptor.HSet(ctx, authKey, map[string]string{dto.UniqueKey: string(atVal)}, pipe)
+		},
+	)
 }
 
 func (s *TokenService) GetById(
@@ -134,28 +391,25 @@ func (s *TokenService) GetByAuthID(
 	return &dto, nil
 }
 
+// FindByAuthID returns the sessions and exchanged tokens of id.
 func (s *TokenService) FindByAuthID(
 	ctx context.Context,
 	id domain.AuthID,
 ) ([]domain.TokenDTO, error) {
-	key := s.buildAuthKey(id)
-	val, err := s.adaptor.HGetAll(ctx, key)
-	if err != nil {
-		return nil, err
-	}
-
 	response := make([]domain.TokenDTO, 0)
-	if val == nil {
-		return response, nil
-	}
-
-	for _, v := range val {
-		dto := domain.TokenDTO{}
-		err = json.Unmarshal([]byte(v), &dto)
+	for _, key := range []string{s.buildAuthKey(id), s.buildExchangedKey(id)} {
+		val, err := s.adaptor.HGetAll(ctx, key)
 		if err != nil {
 			return nil, err
 		}
-		response = append(response, dto)
+		for _, v := range val {
+			dto := domain.TokenDTO{}
+			err = json.Unmarshal([]byte(v), &dto)
+			if err != nil {
+				return nil, err
+			}
+			response = append(response, dto)
+		}
 	}
 	return response, nil
 }
@@ -180,8 +434,7 @@ func (s *TokenService) DeleteAuth(
 	ctx context.Context,
 	id domain.AuthID,
 ) (bool, error) {
-	key := s.buildAuthKey(id)
-	val, err := s.adaptor.Delete(ctx, key)
+	val, err := s.adaptor.DeleteMultiple(ctx, []string{s.buildAuthKey(id), s.buildExchangedKey(id)}, nil)
 	if err != nil {
 		return false, err
 	}
@@ -200,7 +453,7 @@ func (s *TokenService) MultiDelete(
 	for i, id := range ids {
 		keys[i] = s.buildKey(id)
 	}
-	val, err := s.adaptor.DeleteMultiple(ctx, keys)
+	val, err := s.adaptor.DeleteMultiple(ctx, keys, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -213,7 +466,7 @@ func (s *TokenService) DeleteAuthFields(
 	fields []string,
 ) (int64, error) {
 	auKey := s.buildAuthKey(authId)
-	val, err := s.adaptor.HDelete(ctx, auKey, fields)
+	val, err := s.adaptor.HDelete(ctx, auKey, fields, nil)
 	if err != nil {
 		return val, err
 	}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+func TestAddExchangedApartFromSessions(t *testing.T) {
+	ctx := context.Background()
+	adaptor := newTestAdaptor(t)
+	service := NewTokenService(adaptor)
+	now := time.Now().UTC()
+	_, _, err := service.Add(ctx, domain.TokenDTO{
+		AuthID:    "user",
+		UniqueKey: "web",
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour),
+	}, domain.SessionPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = service.Add(ctx, domain.TokenDTO{
+		AuthID:    "user",
+		UniqueKey: "tx-one",
+		Exchanged: true,
+		CreatedAt: now,
+		ExpiresAt: now.Add(15 * time.Minute),
+	}, domain.SessionPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := adaptor.HGetAll(ctx, service.buildAuthKey("user"))
+	if err != nil || len(sessions) != 1 || sessions["web"] == "" {
+		t.Fatalf("sessions = %v, %v, want only web", sessions, err)
+	}
+	ttl := adaptor.redisClient.TTL(ctx, adaptor.buildKey(service.buildExchangedKey("user"))).Val()
+	if ttl <= 0 || ttl > 15*time.Minute {
+		t.Fatalf("exchanged key TTL = %v, want the exchanged token lifetime", ttl)
+	}
+
+	tokens, err := service.FindByAuthID(ctx, "user")
+	if err != nil || len(tokens) != 2 {
+		t.Fatalf("FindByAuthID = %v, %v, want the session and the exchanged token", tokens, err)
+	}
+	_, err = service.DeleteAuth(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err = service.FindByAuthID(ctx, "user")
+	if err != nil || len(tokens) != 0 {
+		t.Fatalf("FindByAuthID after DeleteAuth = %v, %v", tokens, err)
+	}
+}
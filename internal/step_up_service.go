@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dev0yager/goauth/audit"
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// Elevate records that the session of accessToken passed an additional
+// authentication by method and re-issues its access token, the previous one
+// is revoked like on refresh. The refresh key stays the same.
+func (s *TokenService) Elevate(
+	ctx context.Context,
+	accessToken string,
+	method string,
+	level int,
+	activity domain.SessionActivity,
+) (*domain.AuthTokenDTO, error) {
+	ctx, span := s.startSpan(ctx, "TokenService.Elevate", "")
+	res, err := s.elevate(ctx, accessToken, method, level, activity)
+	endSpan(span, err)
+	return res, err
+}
+
+func (s *TokenService) elevate(
+	ctx context.Context,
+	accessToken string,
+	method string,
+	level int,
+	activity domain.SessionActivity,
+) (*domain.AuthTokenDTO, error) {
+	at, err := s.validate(ctx, accessToken, activity)
+	if err != nil {
+		return nil, err
+	}
+	// only user sessions authenticate interactively
+	if !at.Refreshable() {
+		return nil, pkg.ErrAuthTokenInvalid
+	}
+	at.Elevate(method, level, time.Now().UTC())
+
+	validity := at.Lifetime
+	if validity == 0 {
+		validity = s.cfg.Validity(at.Role, at.UniqueKey)
+	}
+	at.Refresh(validity)
+	return s.issue(ctx, *at, audit.EventSessionElevated)
+}
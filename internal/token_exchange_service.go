@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+const exchangeUniqueKeySize = 12
+
+// ExchangeToken issues a token for the subject of subjectToken restricted to
+// dto (RFC 8693). With an actorToken the new token is delegated to its bearer,
+// whose role must be allowed to act for others.
+func (s *TokenService) ExchangeToken(
+	ctx context.Context,
+	subjectToken string,
+	actorToken string,
+	dto domain.TokenExchangeDTO,
+	activity domain.SessionActivity,
+) (*domain.AuthTokenDTO, *domain.TokenDTO, error) {
+	ctx, span := s.startSpan(ctx, "TokenService.ExchangeToken", "")
+	res, token, err := s.exchangeToken(ctx, subjectToken, actorToken, dto, activity)
+	endSpan(span, err)
+	return res, token, err
+}
+
+func (s *TokenService) exchangeToken(
+	ctx context.Context,
+	subjectToken string,
+	actorToken string,
+	dto domain.TokenExchangeDTO,
+	activity domain.SessionActivity,
+) (*domain.AuthTokenDTO, *domain.TokenDTO, error) {
+	subject, err := s.validate(ctx, subjectToken, activity)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dto.Role == "" {
+		dto.Role = subject.Role
+	}
+	if !s.cfg.MayExchange(subject.Role, dto.Role) {
+		return nil, nil, pkg.ErrTokenExchangeDenied
+	}
+	if dto.Scopes == nil {
+		dto.Scopes = subject.Scopes
+	}
+	if !domain.IsSubset(dto.Scopes, subject.Scopes) {
+		return nil, nil, pkg.ErrAuthScopeMismatch
+	}
+	if dto.Audience == nil {
+		dto.Audience = subject.Audience
+	}
+	if !s.cfg.MayExchangeAudience(subject.Role, dto.Audience, s.audienceClaim(*subject)) {
+		return nil, nil, pkg.ErrTokenExchangeDenied
+	}
+
+	// without an actor the token keeps the delegation chain of the subject token
+	actor := subject.Actor
+	if actorToken != "" {
+		at, err := s.validate(ctx, actorToken, activity)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !s.cfg.MayAct(at.Role) {
+			return nil, nil, pkg.ErrTokenExchangeDenied
+		}
+		actor = &domain.ActorDTO{
+			AuthID:  at.AuthID,
+			Role:    at.Role,
+			Subject: at.Subject,
+			Act:     subject.Actor,
+		}
+	}
+
+	// exchanged tokens never outlive the subject token
+	validity := s.cfg.ExchangeValidity
+	if dto.Lifetime > 0 && dto.Lifetime < validity {
+		validity = dto.Lifetime
+	}
+	remaining := time.Until(subject.ExpiresAt)
+	if remaining < validity {
+		validity = remaining
+	}
+
+	uniqueKey, err := domain.RandomString(exchangeUniqueKeySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	ts := time.Now().UTC()
+	token := domain.TokenDTO{
+		AuthID:      subject.AuthID,
+		Role:        dto.Role,
+		UniqueKey:   "tx-" + uniqueKey,
+		Subject:     subject.Subject,
+		Audience:    dto.Audience,
+		Scopes:      dto.Scopes,
+		Actor:       actor,
+		Exchanged:   true,
+		AuthTime:    subject.AuthTime,
+		AuthMethods: subject.AuthMethods,
+		AuthLevel:   subject.AuthLevel,
+		Lifetime:    validity,
+		StartedAt:   ts,
+		CreatedAt:   ts,
+		ExpiresAt:   ts.Add(validity),
+	}
+	res, err := s.create(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, &token, nil
+}
@@ -5,34 +5,128 @@ import (
 	b64 "encoding/base64"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
+	"github.com/c0dev0yager/goauth/audit"
 	"github.com/c0dev0yager/goauth/internal/domain"
 	"github.com/c0dev0yager/goauth/internal/repository"
+	"github.com/c0dev0yager/goauth/metrics"
 	"github.com/c0dev0yager/goauth/pkg"
 )
 
 type TokenService struct {
-	rep *repository.TokenRepository
-	cfg domain.TokenConfig
+	rep     *repository.TokenRepository
+	cfg     domain.TokenConfig
+	audit   audit.Sink
+	metrics metrics.Recorder
+	tracer  trace.Tracer
 }
 
+// NewTokenService builds the service on redisClient. A nil tracer disables
+// tracing of both the service methods and the Redis commands.
 func NewTokenService(
 	redisClient *redis.Client,
 	tokenConfig domain.TokenConfig,
+	auditSink audit.Sink,
+	recorder metrics.Recorder,
+	tracer trace.Tracer,
 ) *TokenService {
 	rep := &repository.TokenRepository{}
-	rep.Build(redisClient)
-	return &TokenService{rep: rep, cfg: tokenConfig}
+	rep.Build(redisClient, recorder, tracer)
+	if tracer == nil {
+		tracer = noop.NewTracerProvider().Tracer(domain.PkgKeyword)
+	}
+	return &TokenService{rep: rep, cfg: tokenConfig, audit: auditSink, metrics: recorder, tracer: tracer}
+}
+
+func (s *TokenService) validationFailed(
+	ctx context.Context,
+	dto domain.TokenDTO,
+	reason string,
+	err error,
+) {
+	s.metrics.ValidationFailed(reason)
+	s.emit(ctx, audit.EventValidationFailed, dto, err)
+}
+
+func (s *TokenService) emit(
+	ctx context.Context,
+	eventType audit.EventType,
+	dto domain.TokenDTO,
+	reason error,
+) {
+	if s.audit == nil {
+		return
+	}
+	var actorID string
+	if dto.Actor != nil {
+		actorID = string(dto.Actor.AuthID)
+	}
+	event := audit.Event{
+		Type:       eventType,
+		AuthID:     string(dto.AuthID),
+		Role:       dto.Role,
+		UniqueKey:  dto.UniqueKey,
+		TokenID:    string(dto.ID),
+		ActorID:    actorID,
+		OccurredAt: time.Now().UTC(),
+	}
+	if reason != nil {
+		event.Reason = reason.Error()
+	}
+	err := s.audit.Emit(ctx, event)
+	if err != nil {
+		domain.LoggerFromContext(ctx).Error(domain.LogKeyword+": Audit", "event", eventType, "error", err)
+	}
+}
+
+func (s *TokenService) Validity(
+	role string,
+	uniqueKey string,
+) time.Duration {
+	return s.cfg.Validity(role, uniqueKey)
 }
 
 func (s *TokenService) Create(
 	ctx context.Context,
 	createDTO domain.TokenDTO,
+) (*domain.AuthTokenDTO, error) {
+	ctx, span := s.startSpan(ctx, "TokenService.Create", createDTO.AuthID)
+	res, err := s.create(ctx, createDTO)
+	endSpan(span, err)
+	return res, err
+}
+
+func (s *TokenService) create(
+	ctx context.Context,
+	createDTO domain.TokenDTO,
+) (*domain.AuthTokenDTO, error) {
+	event := audit.EventTokenCreated
+	if createDTO.Exchanged {
+		event = audit.EventTokenExchanged
+	}
+	res, err := s.issue(ctx, createDTO, event)
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.TokenCreated(createDTO.Role)
+	return res, nil
+}
+
+// issue stores createDTO as the session of its unique key and reports it as
+// event.
+func (s *TokenService) issue(
+	ctx context.Context,
+	createDTO domain.TokenDTO,
+	event audit.EventType,
 ) (*domain.AuthTokenDTO, error) {
 	refreshKeyVal := fmt.Sprintf("aid::%s::ro::%s::uk::%s", createDTO.AuthID, createDTO.Role, createDTO.UniqueKey)
 	rid, err := domain.Aes256Encode(refreshKeyVal, s.cfg.EncKey, s.cfg.EncIV)
@@ -40,12 +134,28 @@ func (s *TokenService) Create(
 		return nil, err
 	}
 
-	dto, err := s.rep.IToken.Add(ctx, createDTO)
+	if createDTO.Format == "" {
+		createDTO.Format = s.cfg.TokenFormat
+	}
+	opaqueToken, err := s.assignOpaqueID(&createDTO)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := s.cfg.SessionPolicy(createDTO.Role)
+	if createDTO.Exchanged {
+		// exchanged tokens never count against nor replace the sessions of the subject
+		policy = domain.SessionPolicy{}
+	}
+	dto, revoked, err := s.rep.IToken.Add(ctx, createDTO, policy)
 	if err != nil {
 		return nil, err
 	}
+	for _, session := range revoked {
+		s.emit(ctx, audit.EventSessionRevoked, session, nil)
+	}
 
-	accessToken, err := s.createJWTToken(*dto)
+	accessToken, err := s.createAccessToken(*dto, opaqueToken)
 	if err != nil {
 		return nil, err
 	}
@@ -56,6 +166,7 @@ func (s *TokenService) Create(
 		ExpiresAt:   dto.ExpiresAt.UnixMilli(),
 	}
 
+	s.emit(ctx, event, *dto, nil)
 	return &res, nil
 }
 
@@ -64,15 +175,34 @@ func (s *TokenService) Refresh(
 	refreshKey string,
 	accessToken string,
 ) (*domain.AuthTokenDTO, error) {
-	claim, err := s.decodeAndVerifyJWT(accessToken)
-	if err != nil {
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, pkg.ErrAuthTokenMalformed
-		}
-		if errors.Is(err, jwt.ErrTokenUnverifiable) {
-			return nil, pkg.ErrAuthTokenInvalid
+	ctx, span := s.startSpan(ctx, "TokenService.Refresh", "")
+	res, err := s.refresh(ctx, refreshKey, accessToken)
+	endSpan(span, err)
+	return res, err
+}
+
+func (s *TokenService) refresh(
+	ctx context.Context,
+	refreshKey string,
+	accessToken string,
+) (*domain.AuthTokenDTO, error) {
+	var tokenID domain.TokenID
+	var subjectClaims *domain.JWTCustomClaims
+	if isOpaqueToken(accessToken) {
+		tokenID = s.opaqueTokenID(accessToken)
+	} else {
+		claim, err := s.decodeAndVerifyJWT(accessToken)
+		if err != nil {
+			if errors.Is(err, jwt.ErrTokenMalformed) {
+				return nil, pkg.ErrAuthTokenMalformed
+			}
+			if errors.Is(err, jwt.ErrTokenUnverifiable) {
+				return nil, pkg.ErrAuthTokenInvalid
+			}
+			return nil, err
 		}
-		return nil, err
+		tokenID = claim.TokenID(s.cfg.AcceptLegacy)
+		subjectClaims = claim
 	}
 
 	encodedKey, err := b64.StdEncoding.DecodeString(refreshKey)
@@ -87,22 +217,44 @@ func (s *TokenService) Refresh(
 
 	authID := domain.AuthID(refreshVal[1])
 	uniqueKey := refreshVal[5]
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String(authIDAttribute, string(authID)))
 
 	tokenDTO, err := s.rep.IToken.GetByAuthID(ctx, authID, uniqueKey)
 	if err != nil {
 		return nil, err
 	}
-	if tokenDTO == nil || string(tokenDTO.ID) != claim.ID {
+	// service and exchanged tokens are re-issued through their grant
+	if tokenDTO == nil || tokenDTO.ID != tokenID || !tokenDTO.Refreshable() {
+		return nil, pkg.ErrAuthRefreshKeyInvalid
+	}
+	if subjectClaims != nil && !s.subjectMatches(subjectClaims, *tokenDTO) {
 		return nil, pkg.ErrAuthRefreshKeyInvalid
 	}
+	if tokenDTO.IsIdle(s.cfg.IdleTimeout(tokenDTO.Role)) {
+		return nil, pkg.ErrAuthSessionIdle
+	}
 
-	tokenDTO.Refresh(s.cfg.JwtValidityInMins)
-	tokenDTO, err = s.rep.IToken.Add(ctx, *tokenDTO)
+	validity := tokenDTO.Lifetime
+	if validity == 0 {
+		validity = s.cfg.Validity(tokenDTO.Role, tokenDTO.UniqueKey)
+	}
+	tokenDTO.Refresh(validity)
+	opaqueToken, err := s.assignOpaqueID(tokenDTO)
 	if err != nil {
 		return nil, err
 	}
+	tokenDTO, revoked, err := s.rep.IToken.Add(ctx, *tokenDTO, s.cfg.SessionPolicy(tokenDTO.Role))
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range revoked {
+		// the refreshed session itself is reported by EventTokenRefreshed
+		if session.UniqueKey != tokenDTO.UniqueKey {
+			s.emit(ctx, audit.EventSessionRevoked, session, nil)
+		}
+	}
 
-	accessToken, err = s.createJWTToken(*tokenDTO)
+	accessToken, err = s.createAccessToken(*tokenDTO, opaqueToken)
 	if err != nil {
 		return nil, err
 	}
@@ -113,12 +265,24 @@ func (s *TokenService) Refresh(
 		ExpiresAt:   tokenDTO.ExpiresAt.UnixMilli(),
 	}
 
+	s.metrics.TokenRefreshed(tokenDTO.Role)
+	s.emit(ctx, audit.EventTokenRefreshed, *tokenDTO, nil)
 	return &res, nil
 }
 
 func (s *TokenService) Invalidate(
 	ctx context.Context,
 	authID domain.AuthID,
+) error {
+	ctx, span := s.startSpan(ctx, "TokenService.Invalidate", authID)
+	err := s.invalidate(ctx, authID)
+	endSpan(span, err)
+	return err
+}
+
+func (s *TokenService) invalidate(
+	ctx context.Context,
+	authID domain.AuthID,
 ) error {
 	tokenDTOS, err := s.rep.IToken.FindByAuthID(
 		ctx,
@@ -142,31 +306,84 @@ func (s *TokenService) Invalidate(
 	if err != nil {
 		return nil
 	}
+	s.metrics.TokenInvalidated(len(tokenDTOS))
+	for _, tokenDTO := range tokenDTOS {
+		s.emit(ctx, audit.EventTokenInvalidated, tokenDTO, nil)
+	}
 	return nil
 }
 
 func (s *TokenService) Validate(
 	ctx context.Context,
 	jwtToken string,
+	activity domain.SessionActivity,
 ) (*domain.TokenDTO, error) {
-	claims, err := s.decodeWithClaims(jwtToken)
-	if err != nil {
-		if errors.Is(err, jwt.ErrTokenExpired) {
-			return nil, pkg.ErrAuthTokenExpired
-		}
-		if errors.Is(err, jwt.ErrTokenMalformed) {
-			return nil, pkg.ErrAuthTokenInvalid
+	ctx, span := s.startSpan(ctx, "TokenService.Validate", "")
+	at, err := s.validate(ctx, jwtToken, activity)
+	if at != nil {
+		span.SetAttributes(attribute.String(authIDAttribute, string(at.AuthID)))
+	}
+	endSpan(span, err)
+	return at, err
+}
+
+func (s *TokenService) validate(
+	ctx context.Context,
+	jwtToken string,
+	activity domain.SessionActivity,
+) (*domain.TokenDTO, error) {
+	// failed describes the token in the audit events of a rejected validation
+	var failed domain.TokenDTO
+	var subjectClaims *domain.JWTCustomClaims
+	if isOpaqueToken(jwtToken) {
+		failed.ID = s.opaqueTokenID(jwtToken)
+	} else {
+		claims, err := s.decodeWithClaims(jwtToken)
+		if err != nil {
+			reason := metrics.ReasonInvalid
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				reason = metrics.ReasonExpired
+				err = pkg.ErrAuthTokenExpired
+			} else if errors.Is(err, jwt.ErrTokenMalformed) {
+				reason = metrics.ReasonMalformed
+				err = pkg.ErrAuthTokenInvalid
+			} else if !errors.Is(err, errJWEKeyMissing) {
+				// signature, algorithm, issuer and audience failures
+				err = pkg.ErrAuthTokenInvalid
+			}
+			s.validationFailed(ctx, domain.TokenDTO{}, reason, err)
+			return nil, err
 		}
-		return nil, err
+		failed = domain.TokenDTO{ID: claims.TokenID(s.cfg.AcceptLegacy), Role: claims.Role}
+		subjectClaims = claims
 	}
 
-	at, err := s.rep.IToken.GetById(ctx, domain.TokenID(claims.ID))
+	at, err := s.rep.IToken.GetById(ctx, failed.ID)
 	if err != nil {
 		return nil, err
 	}
 	if at == nil || at.ExpiresAt.Before(time.Now().UTC()) {
+		s.validationFailed(ctx, failed, metrics.ReasonExpired, pkg.ErrAuthTokenExpired)
 		return nil, pkg.ErrAuthTokenExpired
 	}
+	if subjectClaims != nil && !s.subjectMatches(subjectClaims, *at) {
+		s.validationFailed(ctx, *at, metrics.ReasonInvalid, pkg.ErrAuthTokenInvalid)
+		return nil, pkg.ErrAuthTokenInvalid
+	}
+	if at.IsIdle(s.cfg.IdleTimeout(at.Role)) {
+		s.validationFailed(ctx, *at, metrics.ReasonIdle, pkg.ErrAuthSessionIdle)
+		return nil, pkg.ErrAuthSessionIdle
+	}
+
+	if activity.SeenAt.IsZero() {
+		activity.SeenAt = time.Now().UTC()
+	}
+	if at.Touch(activity, s.cfg.ActivityThrottle) {
+		err = s.rep.IToken.Touch(ctx, *at)
+		if err != nil {
+			domain.LoggerFromContext(ctx).Error(domain.LogKeyword+": Validate Touch", "error", err)
+		}
+	}
 	return at, nil
 }
 
@@ -175,33 +392,49 @@ func (s *TokenService) createJWTToken(
 ) (string, error) {
 	current := &jwt.NumericDate{Time: time.Now().UTC()}
 	claims := domain.JWTCustomClaims{
-		ID:   string(tokenDTO.ID),
-		Role: tokenDTO.Role,
+		Role:  tokenDTO.Role,
+		Scope: strings.Join(tokenDTO.Scopes, " "),
+		Act:   s.actorClaim(tokenDTO.Actor),
+		AMR:   tokenDTO.AuthMethods,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        string(tokenDTO.ID),
+			Subject:   s.subjectClaim(tokenDTO),
+			Audience:  s.audienceClaim(tokenDTO),
 			ExpiresAt: &jwt.NumericDate{Time: tokenDTO.ExpiresAt},
 			IssuedAt:  current,
 			NotBefore: current,
-			Issuer:    domain.PkgKeyword,
+			Issuer:    s.cfg.Issuer,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if !tokenDTO.AuthTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(tokenDTO.AuthTime)
+	}
+	if tokenDTO.AuthLevel > 0 {
+		claims.ACR = strconv.Itoa(tokenDTO.AuthLevel)
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(s.cfg.SigningMethod), claims)
 
 	accessToken, err := token.SignedString(s.cfg.JwtKey)
 	if err != nil {
 		return "", err
 	}
 
-	return accessToken, nil
+	return s.encryptToken(accessToken)
 }
 
 func (s *TokenService) decodeWithClaims(
 	tokenString string,
 ) (*domain.JWTCustomClaims, error) {
+	tokenString, err := s.decryptToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
 	token, err := jwt.ParseWithClaims(
 		tokenString, &domain.JWTCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 			return s.cfg.JwtKey, nil
-		},
+		}, s.parserOptions()...,
 	)
 	if err != nil {
 		return nil, err
@@ -210,31 +443,39 @@ func (s *TokenService) decodeWithClaims(
 	if !ok {
 		return nil, jwt.ErrTokenMalformed
 	}
+	if s.cfg.AcceptLegacy {
+		err = s.verifyRegisteredClaims(claims)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", jwt.ErrTokenUnverifiable, err)
+		}
+	}
 	return claims, nil
 }
 
 func (s *TokenService) decodeAndVerifyJWT(
 	tokenString string,
 ) (*domain.JWTCustomClaims, error) {
-	token, err := jwt.Parse(
-		tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
+	tokenString, err := s.decryptToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	// expired tokens are accepted, the refresh key decides if the session goes on
+	token, err := jwt.ParseWithClaims(
+		tokenString, &domain.JWTCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 			return s.cfg.JwtKey, nil
-		}, jwt.WithoutClaimsValidation(),
+		}, jwt.WithValidMethods([]string{s.cfg.SigningMethod}), jwt.WithoutClaimsValidation(),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	claims, ok := token.Claims.(*domain.JWTCustomClaims)
 	if !ok {
 		return nil, jwt.ErrTokenMalformed
 	}
-	claims := domain.JWTCustomClaims{
-		ID:   mapClaims["id"].(string),
-		Role: mapClaims["role"].(string),
+	err = s.verifyRegisteredClaims(claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", jwt.ErrTokenUnverifiable, err)
 	}
-	return &claims, nil
+	return claims, nil
 }
@@ -0,0 +1,335 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+const (
+	mfaTokenSize   = 32
+	mfaMaxAttempts = 5
+	// totpFailureScope is the window of wrong second factor codes per auth ID
+	totpFailureScope = "totp-failure"
+)
+
+// EnrollTOTP generates a new unconfirmed secret for authID and returns it with
+// its otpauth URI, a confirmed factor must be disabled first.
+func (s *TokenService) EnrollTOTP(
+	ctx context.Context,
+	authID domain.AuthID,
+	account string,
+) (string, string, error) {
+	current, err := s.rep.IMFA.GetTOTP(ctx, authID)
+	if err != nil {
+		return "", "", err
+	}
+	if current != nil && current.Confirmed {
+		return "", "", pkg.ErrTOTPAlreadyEnrolled
+	}
+	secret, err := domain.NewTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := domain.Aes256Encode(secret, s.cfg.EncKey, s.cfg.EncIV)
+	if err != nil {
+		return "", "", err
+	}
+	err = s.rep.IMFA.SaveTOTP(ctx, domain.TOTPDTO{
+		AuthID:    authID,
+		Secret:    encrypted,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return secret, domain.TOTPURI(s.cfg.TOTPIssuer, account, secret), nil
+}
+
+// ConfirmTOTP enables the enrolled factor once code proves the authenticator
+// app holds the secret, it returns the recovery codes shown to the user once.
+func (s *TokenService) ConfirmTOTP(
+	ctx context.Context,
+	authID domain.AuthID,
+	code string,
+) ([]string, error) {
+	dto, err := s.rep.IMFA.GetTOTP(ctx, authID)
+	if err != nil {
+		return nil, err
+	}
+	if dto == nil {
+		return nil, pkg.ErrTOTPNotEnrolled
+	}
+	if dto.Confirmed {
+		return nil, pkg.ErrTOTPAlreadyEnrolled
+	}
+	counter, err := s.matchTOTP(*dto, code)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	dto.Confirmed = true
+	dto.LastCounter = counter
+	dto.RecoveryCodes = hashes
+	err = s.rep.IMFA.SaveTOTP(ctx, *dto)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyTOTP checks code against the confirmed factor of authID, each time
+// step is accepted once. Wrong codes count towards the lockout of authID.
+func (s *TokenService) VerifyTOTP(
+	ctx context.Context,
+	authID domain.AuthID,
+	code string,
+) error {
+	return s.limitSecondFactor(ctx, authID, func() error {
+		return s.verifyTOTP(ctx, authID, code)
+	})
+}
+
+func (s *TokenService) verifyTOTP(
+	ctx context.Context,
+	authID domain.AuthID,
+	code string,
+) error {
+	dto, err := s.rep.IMFA.GetTOTP(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if dto == nil || !dto.Confirmed {
+		return pkg.ErrTOTPNotEnrolled
+	}
+	counter, err := s.matchTOTP(*dto, code)
+	if err != nil {
+		return err
+	}
+	used, err := s.rep.IMFA.UseTOTPCounter(ctx, authID, counter)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return pkg.ErrTOTPInvalid
+	}
+	return nil
+}
+
+func (s *TokenService) DisableTOTP(
+	ctx context.Context,
+	authID domain.AuthID,
+) error {
+	found, err := s.rep.IMFA.DeleteTOTP(ctx, authID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return pkg.ErrTOTPNotEnrolled
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of authID.
+func (s *TokenService) RegenerateRecoveryCodes(
+	ctx context.Context,
+	authID domain.AuthID,
+) ([]string, error) {
+	codes, hashes, err := s.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	found, err := s.rep.IMFA.SetRecoveryCodes(ctx, authID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, pkg.ErrTOTPNotEnrolled
+	}
+	return codes, nil
+}
+
+// BeginMFA holds token back when its auth ID has a confirmed second factor
+// and returns the MFA token redeeming it through CompleteMFA. The MFA token
+// is empty when no second factor is needed.
+func (s *TokenService) BeginMFA(
+	ctx context.Context,
+	token domain.TokenDTO,
+	idToken *domain.IDTokenDTO,
+) (string, *domain.MFAPendingDTO, error) {
+	dto, err := s.rep.IMFA.GetTOTP(ctx, token.AuthID)
+	if err != nil {
+		return "", nil, err
+	}
+	if dto == nil || !dto.Confirmed {
+		return "", nil, nil
+	}
+	mfaToken, err := domain.RandomString(mfaTokenSize)
+	if err != nil {
+		return "", nil, err
+	}
+	pending := domain.MFAPendingDTO{
+		Token:     token,
+		IDToken:   idToken,
+		ExpiresAt: time.Now().UTC().Add(s.cfg.MFAValidity),
+	}
+	err = s.rep.IMFA.AddMFAPending(ctx, domain.HashSecret(mfaToken, s.cfg.EncKey), pending)
+	if err != nil {
+		return "", nil, err
+	}
+	return mfaToken, &pending, nil
+}
+
+// CompleteMFA creates the session held back by BeginMFA once code, a TOTP
+// or recovery code, is verified, with the ID token the login asked for. The
+// MFA token is revoked after mfaMaxAttempts wrong codes.
+func (s *TokenService) CompleteMFA(
+	ctx context.Context,
+	mfaToken string,
+	code string,
+) (*domain.AuthTokenDTO, string, error) {
+	if mfaToken == "" {
+		return nil, "", pkg.ErrMFATokenInvalid
+	}
+	tokenHash := domain.HashSecret(mfaToken, s.cfg.EncKey)
+	pending, err := s.rep.IMFA.TakeMFAPending(ctx, tokenHash)
+	if err != nil {
+		return nil, "", err
+	}
+	if pending == nil || pending.ExpiresAt.Before(time.Now().UTC()) {
+		return nil, "", pkg.ErrMFATokenInvalid
+	}
+
+	method, err := s.verifySecondFactor(ctx, pending.Token.AuthID, code)
+	if errors.Is(err, pkg.ErrTOTPInvalid) {
+		pending.Attempts++
+		if pending.Attempts < mfaMaxAttempts {
+			addErr := s.rep.IMFA.AddMFAPending(ctx, tokenHash, *pending)
+			if addErr != nil {
+				return nil, "", addErr
+			}
+		}
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := pending.Token
+	token.Elevate(method, domain.AuthLevelMultiFactor, time.Now().UTC())
+	tokenResponse, err := s.createGranted(ctx, token)
+	if err != nil || pending.IDToken == nil {
+		return tokenResponse, "", err
+	}
+	idToken := *pending.IDToken
+	idToken.AccessToken = tokenResponse.AccessToken
+	if !slices.Contains(idToken.AMR, method) {
+		idToken.AMR = append(idToken.AMR, method)
+	}
+	idToken.ACR = strconv.Itoa(domain.AuthLevelMultiFactor)
+	signed, err := s.CreateIDToken(idToken)
+	if err != nil {
+		return nil, "", err
+	}
+	return tokenResponse, signed, nil
+}
+
+// verifySecondFactor accepts a TOTP code or a recovery code and returns the
+// authentication method used. Wrong codes of both kinds count towards the
+// lockout of authID.
+func (s *TokenService) verifySecondFactor(
+	ctx context.Context,
+	authID domain.AuthID,
+	code string,
+) (string, error) {
+	code = strings.TrimSpace(code)
+	if len(code) == domain.TOTPDigits {
+		return domain.AuthMethodOTP, s.VerifyTOTP(ctx, authID, code)
+	}
+	err := s.limitSecondFactor(ctx, authID, func() error {
+		codeHash := domain.HashSecret(domain.NormalizeRecoveryCode(code), s.cfg.EncKey)
+		used, err := s.rep.IMFA.UseRecoveryCode(ctx, authID, codeHash)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return pkg.ErrTOTPInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return domain.AuthMethodRecoveryCode, nil
+}
+
+// limitSecondFactor runs verify unless authID is locked out and counts the
+// codes it rejects, TOTPMaxFailures of them within TOTPLockout lock authID out.
+func (s *TokenService) limitSecondFactor(
+	ctx context.Context,
+	authID domain.AuthID,
+	verify func() error,
+) error {
+	lockout := failureLockout{
+		Scope:    totpFailureScope,
+		Failures: s.cfg.TOTPMaxFailures,
+		Window:   s.cfg.TOTPLockout,
+		Duration: s.cfg.TOTPLockout,
+		Err:      pkg.ErrTOTPLocked,
+	}
+	err := s.checkLock(ctx, lockout, string(authID))
+	if err != nil {
+		return err
+	}
+	err = verify()
+	if errors.Is(err, pkg.ErrTOTPInvalid) {
+		lockErr := s.recordFailure(ctx, lockout, string(authID))
+		if lockErr != nil {
+			return lockErr
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return s.resetFailures(ctx, lockout, string(authID))
+}
+
+func (s *TokenService) matchTOTP(
+	dto domain.TOTPDTO,
+	code string,
+) (int64, error) {
+	secret, err := domain.Aes256Decode(dto.Secret, s.cfg.EncKey, s.cfg.EncIV)
+	if err != nil {
+		return 0, err
+	}
+	counter, ok := domain.MatchTOTP(secret, strings.TrimSpace(code), time.Now().UTC(), s.cfg.TOTPSkew)
+	if !ok {
+		return 0, pkg.ErrTOTPInvalid
+	}
+	return counter, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and the hashes stored for them.
+func (s *TokenService) newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, domain.RecoveryCodeCount)
+	hashes := make([]string, domain.RecoveryCodeCount)
+	for i := range codes {
+		code, err := domain.NewRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = code
+		hashes[i] = domain.HashSecret(domain.NormalizeRecoveryCode(code), s.cfg.EncKey)
+	}
+	return codes, hashes, nil
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+)
+
+const (
+	authIDAttribute     = "goauth.auth_id"
+	trackingIDAttribute = "goauth.tracking_id"
+)
+
+func (s *TokenService) startSpan(
+	ctx context.Context,
+	name string,
+	authID domain.AuthID,
+) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String(trackingIDAttribute, domain.TrackingID(ctx)),
+	}
+	if authID != "" {
+		attributes = append(attributes, attribute.String(authIDAttribute, string(authID)))
+	}
+	return s.tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+func endSpan(
+	span trace.Span,
+	err error,
+) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,104 @@
+package goauth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+type LoginValue struct {
+	Login     string `json:"login" validate:"required,max=254"`
+	Password  string `json:"password" validate:"required,max=1024"`
+	UniqueKey string `json:"unique_key" validate:"max=100,special_character_validation"`
+}
+
+// Login verifies the password of dto.Login against Config.CredentialStore and
+// creates its session like CreateToken. Unknown logins and wrong passwords
+// both fail with pkg.ErrInvalidCredentials, hashes with outdated parameters
+// are replaced on success. Config.LockoutMaxFailures wrong passwords lock the
+// login out with pkg.ErrAccountLocked, and RateLimitLogin throttles the client
+// IP and login with pkg.ErrRateLimited.
+func (cl *authClient) Login(
+	ctx context.Context,
+	dto LoginValue,
+) (*TokenResponseDTO, error) {
+	store := cl.config.CredentialStore
+	if store == nil {
+		return nil, pkg.ErrNoCredentialStore
+	}
+	err := pkg.Validate.Struct(dto)
+	if err != nil {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": Login Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	// case and spacing variants of a login share its limits
+	account := strings.ToLower(strings.TrimSpace(dto.Login))
+	err = cl.throttle(ctx, RateLimitLogin, "login:"+account)
+	if err != nil {
+		return nil, err
+	}
+	err = cl.ts.CheckLockout(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := cl.config.PasswordHasher
+	credential, err := store.FindCredential(ctx, dto.Login)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		hasher.VerifyDummy(dto.Password)
+		return nil, cl.loginFailed(ctx, account)
+	}
+	match, rehash, err := hasher.Verify(dto.Password, credential.PasswordHash)
+	if err != nil {
+		return nil, err
+	}
+	if !match {
+		return nil, cl.loginFailed(ctx, account)
+	}
+	err = cl.ts.ResetFailures(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	if rehash {
+		// a failed rehash does not fail the login, the old hash stays valid
+		passwordHash, err := hasher.Hash(dto.Password)
+		if err == nil {
+			err = store.UpdatePasswordHash(ctx, credential.AuthID, passwordHash)
+		}
+		if err != nil {
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": Login Rehash", "error", err)
+		}
+	}
+
+	tokenValue := TokenValue{
+		AuthID:      credential.AuthID,
+		Role:        credential.Role,
+		UniqueKey:   dto.UniqueKey,
+		AuthMethods: []string{AuthMethodPassword},
+		AuthLevel:   AuthLevelSingleFactor,
+	}
+	err = pkg.Validate.Struct(tokenValue)
+	if err != nil {
+		pkg.GetFromContext(ctx).Error(domain.LogKeyword+": Login Credential", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	return cl.createSession(ctx, tokenValue.ToInternalToken(), nil)
+}
+
+// loginFailed counts the failure towards the lockout of account, the failure
+// locking it out returns pkg.ErrAccountLocked.
+func (cl *authClient) loginFailed(
+	ctx context.Context,
+	account string,
+) error {
+	err := cl.ts.RecordFailure(ctx, account)
+	if err != nil {
+		return err
+	}
+	return pkg.ErrInvalidCredentials
+}
@@ -2,29 +2,209 @@ package goauth
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"crypto/rsa"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
+	"github.com/c0dev0yager/goauth/audit"
+	"github.com/c0dev0yager/goauth/credentials"
 	"github.com/c0dev0yager/goauth/internal"
 	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/metrics"
+	"github.com/c0dev0yager/goauth/otp"
 	"github.com/c0dev0yager/goauth/pkg"
 )
 
+const tracerName = "github.com/c0dev0yager/goauth"
+
 type Config struct {
 	JwtKey            string
 	JwtValidityInMins int
 	EncKey            string
 	EnvIV             string
+	LifetimePolicies  []LifetimePolicy
+	SessionLimits     []SessionLimit
+	// ReplacedTokenOverlapInSecs keeps the previous access token of a unique key
+	// valid for in-flight requests after a re-login or refresh, zero revokes it at once
+	ReplacedTokenOverlapInSecs int
+	IdleTimeouts               []IdleTimeout
+	// ActivityThrottleInSecs is the minimum interval between two last-seen
+	// writes of a session, defaults to a minute
+	ActivityThrottleInSecs int
+	// AuditSink receives token lifecycle events, nil disables auditing
+	AuditSink audit.Sink
+	// Metrics records token operation counters and repository latencies,
+	// see metrics.NewPrometheusRecorder
+	Metrics metrics.Recorder
+	// TracerProvider enables OpenTelemetry spans for Authenticate, the token
+	// service and every Redis command, nil disables tracing
+	TracerProvider trace.TracerProvider
+	// Logger receives the package logs, request scoped loggers derive from it.
+	// Nil logs JSON to stderr.
+	Logger *slog.Logger
+	// APIKeyPrefix starts every generated API key, defaults to "gak"
+	APIKeyPrefix string
+	// TokenFormat of the access tokens, TokenValue.TokenFormat overrides it per
+	// token. Defaults to TokenFormatJWT.
+	TokenFormat TokenFormat
+	// TokenEncryption wraps JWT access tokens as JWE with A256GCM content
+	// encryption. TokenEncryptionDirect derives its key from EncKey,
+	// TokenEncryptionRSAOAEP256 requires JWEPrivateKey. Signed tokens are
+	// then rejected unless AcceptUnencryptedTokens keeps those issued before
+	// enabling encryption valid while migrating.
+	TokenEncryption         TokenEncryption
+	JWEPrivateKey           *rsa.PrivateKey
+	AcceptUnencryptedTokens bool
+	// SigningMethod is the only JWT algorithm issued and accepted, one of
+	// HS256 (default), HS384 or HS512. NewSingletonClient panics on others.
+	SigningMethod string
+	// Issuer is set as iss and required on validation, defaults to "goauth"
+	Issuer string
+	// Audience identifies this service, tokens must list it in aud. Tokens
+	// are issued for it unless TokenValue.Audience targets other services.
+	Audience string
+	// ClockSkewInSecs tolerates clock drift on exp, nbf and iat
+	ClockSkewInSecs int
+	// HashSubject puts a keyed hash of the auth ID in sub instead of the auth ID
+	HashSubject bool
+	// AcceptLegacyTokens accepts JWTs issued before the registered claims,
+	// carrying their ID in the id claim and no iss or sub. Only enable it
+	// while such tokens are still in use after an upgrade.
+	AcceptLegacyTokens bool
+	// IDTokenSigningKey signs OpenID Connect ID tokens with RS256 and is
+	// published by JWKSHandler. IDTokenKeyID defaults to the key thumbprint.
+	IDTokenSigningKey     *rsa.PrivateKey
+	IDTokenKeyID          string
+	IDTokenValidityInMins int
+	// AuthorizationCodeValidityInSecs bounds the authorization code exchange,
+	// defaults to a minute
+	AuthorizationCodeValidityInSecs int
+	// DeviceCodeValidityInSecs bounds the device authorization, defaults to
+	// 10 minutes. DevicePollIntervalInSecs defaults to 5 seconds.
+	DeviceCodeValidityInSecs int
+	DevicePollIntervalInSecs int
+	// TokenExchangePolicies list the roles a token may be exchanged for,
+	// ActorRoles the roles allowed to act on behalf of other subjects.
+	TokenExchangePolicies []TokenExchangePolicy
+	ActorRoles            []string
+	// ExchangedTokenValidityInMins bounds exchanged tokens, which never outlive
+	// their subject token. Defaults to 15 minutes.
+	ExchangedTokenValidityInMins int
+	// TOTPIssuer names the account in authenticator apps, defaults to Issuer.
+	// TOTPSkewSteps accepts codes of that many 30 second steps around the
+	// current one, defaults to 1. An auth ID is locked out of VerifyTOTP and
+	// VerifyMFA for TOTPLockoutInMins (default 15) once TOTPMaxFailures
+	// (default 5) wrong codes were given within that time.
+	TOTPIssuer        string
+	TOTPSkewSteps     int
+	TOTPMaxFailures   int
+	TOTPLockoutInMins int
+	// MFATokenValidityInSecs bounds the second factor step of a login,
+	// defaults to 5 minutes
+	MFATokenValidityInSecs int
+	// OTPSender delivers the codes and magic links of SendOTP
+	OTPSender otp.Sender
+	// OTPValidityInSecs defaults to 10 minutes and OTPLength to 6 digits. A
+	// recipient is locked out for OTPLockoutInMins (default 15) once codes
+	// sent to it are guessed wrong OTPMaxAttempts (default 5) times within
+	// that time, across all of its challenges.
+	OTPValidityInSecs int
+	OTPLength         int
+	OTPMaxAttempts    int
+	OTPLockoutInMins  int
+	// CredentialStore looks up the password hashes verified by Login
+	CredentialStore credentials.CredentialStore
+	// PasswordHasher verifies and rehashes passwords, defaults to Argon2id
+	// with credentials.DefaultArgon2idParams
+	PasswordHasher *credentials.Hasher
+	// RateLimits throttle the calls of a scope per client IP and per login,
+	// recipient, refresh key or auth ID, scopes without a limit are not
+	// throttled
+	RateLimits []RateLimit
+	// LockoutMaxFailures wrong passwords of a login within
+	// LockoutWindowInMins (default 15) lock it out of Login for
+	// LockoutDurationInMins (default 15), zero disables the lockout
+	LockoutMaxFailures    int
+	LockoutWindowInMins   int
+	LockoutDurationInMins int
+}
+
+// IdleTimeout rejects sessions unused for longer than TimeoutInMins. An empty
+// Role sets the default timeout.
+type IdleTimeout struct {
+	Role          string
+	TimeoutInMins int
+}
+
+// LifetimePolicy overrides JwtValidityInMins for a role. When UniqueKey is set
+// the policy only applies to tokens issued for that unique key (device class).
+// Policies without a positive ValidityInMins are ignored.
+type LifetimePolicy struct {
+	Role           string
+	UniqueKey      string
+	ValidityInMins int
+}
+
+// TokenExchangePolicy lets tokens of Role be exchanged for tokens of one of
+// ExchangeRoles, usually less privileged ones. Exchanged tokens are restricted
+// to the audience of the subject token unless Audiences lists other services.
+type TokenExchangePolicy struct {
+	Role          string
+	ExchangeRoles []string
+	Audiences     []string
+}
+
+type SubjectType = domain.SubjectType
+
+const (
+	SubjectUser    = domain.SubjectUser
+	SubjectService = domain.SubjectService
+)
+
+type TokenFormat = domain.TokenFormat
+
+const (
+	TokenFormatJWT    = domain.TokenFormatJWT
+	TokenFormatOpaque = domain.TokenFormatOpaque
+)
+
+type TokenEncryption = domain.TokenEncryption
+
+const (
+	TokenEncryptionNone       = domain.TokenEncryptionNone
+	TokenEncryptionDirect     = domain.TokenEncryptionDirect
+	TokenEncryptionRSAOAEP256 = domain.TokenEncryptionRSAOAEP256
+)
+
+type SessionLimitPolicy = domain.SessionLimitPolicy
+
+const (
+	SessionLimitReject                 = domain.SessionLimitReject
+	SessionLimitEvictOldest            = domain.SessionLimitEvictOldest
+	SessionLimitEvictLeastRecentlyUsed = domain.SessionLimitEvictLeastRecentlyUsed
+)
+
+// SessionLimit caps the concurrent sessions (unique keys) per auth ID. An empty
+// Role sets the default limit, a role specific limit only counts that role's sessions.
+type SessionLimit struct {
+	Role        string
+	MaxSessions int
+	Policy      SessionLimitPolicy
 }
 
 type authClient struct {
-	config Config
-	ts     *internal.TokenService
+	config  Config
+	ts      *internal.TokenService
+	audit   audit.Sink
+	metrics metrics.Recorder
+	tracer  trace.Tracer
 }
 
 var cl *authClient
@@ -33,20 +213,181 @@ func NewSingletonClient(
 	cf Config,
 	rs *redis.Client,
 ) {
-	domain.NewLoggerClient(logrus.InfoLevel)
+	domain.NewLoggerClient(cf.Logger)
 
 	tokenConfig := domain.TokenConfig{
 		JwtKey:            []byte(cf.JwtKey),
 		JwtValidityInMins: time.Duration(cf.JwtValidityInMins) * time.Minute,
 		EncKey:            []byte(cf.EncKey),
 		EncIV:             []byte(cf.EnvIV),
+		Lifetimes:         make(map[string]time.Duration),
+		SessionLimits:     make(map[string]domain.SessionLimit),
+		ReplacedOverlap:   time.Duration(cf.ReplacedTokenOverlapInSecs) * time.Second,
+		IdleTimeouts:      make(map[string]time.Duration),
+		ActivityThrottle:  time.Duration(cf.ActivityThrottleInSecs) * time.Second,
+		APIKeyPrefix:      cf.APIKeyPrefix,
+		TokenFormat:       cf.TokenFormat,
+		TokenEncryption:   cf.TokenEncryption,
+		AcceptUnencrypted: cf.AcceptUnencryptedTokens,
+		JWEPrivateKey:     cf.JWEPrivateKey,
+		SigningMethod:     cf.SigningMethod,
+		Issuer:            cf.Issuer,
+		Audience:          cf.Audience,
+		ClockSkew:         time.Duration(cf.ClockSkewInSecs) * time.Second,
+		HashSubject:       cf.HashSubject,
+		AcceptLegacy:      cf.AcceptLegacyTokens,
+		IDTokenKey:        cf.IDTokenSigningKey,
+		IDTokenKeyID:      cf.IDTokenKeyID,
+		IDTokenValidity:   time.Duration(cf.IDTokenValidityInMins) * time.Minute,
+		CodeValidity:      time.Duration(cf.AuthorizationCodeValidityInSecs) * time.Second,
+		DeviceValidity:    time.Duration(cf.DeviceCodeValidityInSecs) * time.Second,
+		DeviceInterval:    time.Duration(cf.DevicePollIntervalInSecs) * time.Second,
+		ExchangeRoles:     make(map[string][]string),
+		ExchangeAudiences: make(map[string][]string),
+		ActorRoles:        cf.ActorRoles,
+		ExchangeValidity:  time.Duration(cf.ExchangedTokenValidityInMins) * time.Minute,
+		TOTPIssuer:        cf.TOTPIssuer,
+		TOTPSkew:          cf.TOTPSkewSteps,
+		TOTPMaxFailures:   cf.TOTPMaxFailures,
+		TOTPLockout:       time.Duration(cf.TOTPLockoutInMins) * time.Minute,
+		MFAValidity:       time.Duration(cf.MFATokenValidityInSecs) * time.Second,
+		OTPValidity:       time.Duration(cf.OTPValidityInSecs) * time.Second,
+		OTPLength:         cf.OTPLength,
+		OTPMaxAttempts:    cf.OTPMaxAttempts,
+		OTPLockout:        time.Duration(cf.OTPLockoutInMins) * time.Minute,
+		RateLimits:        make(map[domain.RateLimitScope]domain.RateLimit),
+		LockoutFailures:   cf.LockoutMaxFailures,
+		LockoutWindow:     time.Duration(cf.LockoutWindowInMins) * time.Minute,
+		LockoutDuration:   time.Duration(cf.LockoutDurationInMins) * time.Minute,
+	}
+	if tokenConfig.ActivityThrottle == 0 {
+		tokenConfig.ActivityThrottle = time.Minute
+	}
+	if tokenConfig.TokenFormat == "" {
+		tokenConfig.TokenFormat = TokenFormatJWT
+	}
+	switch tokenConfig.SigningMethod {
+	case "HS256", "HS384", "HS512":
+	case "":
+		tokenConfig.SigningMethod = "HS256"
+	default:
+		// falling back would issue and accept tokens of another algorithm
+		domain.Logger().Error(domain.LogKeyword+": UnsupportedSigningMethod", "method", tokenConfig.SigningMethod)
+		panic(domain.LogKeyword + ": unsupported SigningMethod " + tokenConfig.SigningMethod)
+	}
+	if tokenConfig.IDTokenValidity == 0 {
+		tokenConfig.IDTokenValidity = tokenConfig.JwtValidityInMins
+	}
+	if tokenConfig.CodeValidity == 0 {
+		tokenConfig.CodeValidity = time.Minute
+	}
+	if tokenConfig.DeviceValidity == 0 {
+		tokenConfig.DeviceValidity = 10 * time.Minute
+	}
+	if tokenConfig.DeviceInterval == 0 {
+		tokenConfig.DeviceInterval = 5 * time.Second
+	}
+	if tokenConfig.ExchangeValidity == 0 {
+		tokenConfig.ExchangeValidity = 15 * time.Minute
+	}
+	if tokenConfig.Issuer == "" {
+		tokenConfig.Issuer = domain.PkgKeyword
+	}
+	if tokenConfig.TOTPIssuer == "" {
+		tokenConfig.TOTPIssuer = tokenConfig.Issuer
+	}
+	if tokenConfig.TOTPSkew == 0 {
+		tokenConfig.TOTPSkew = 1
+	}
+	if tokenConfig.TOTPMaxFailures == 0 {
+		tokenConfig.TOTPMaxFailures = 5
+	}
+	if tokenConfig.TOTPLockout == 0 {
+		tokenConfig.TOTPLockout = 15 * time.Minute
+	}
+	if tokenConfig.MFAValidity == 0 {
+		tokenConfig.MFAValidity = 5 * time.Minute
+	}
+	if tokenConfig.OTPValidity == 0 {
+		tokenConfig.OTPValidity = 10 * time.Minute
+	}
+	if tokenConfig.OTPLength == 0 {
+		tokenConfig.OTPLength = 6
+	}
+	if tokenConfig.OTPMaxAttempts == 0 {
+		tokenConfig.OTPMaxAttempts = 5
+	}
+	if tokenConfig.OTPLockout == 0 {
+		tokenConfig.OTPLockout = 15 * time.Minute
+	}
+	if tokenConfig.LockoutWindow == 0 {
+		tokenConfig.LockoutWindow = 15 * time.Minute
+	}
+	if tokenConfig.LockoutDuration == 0 {
+		tokenConfig.LockoutDuration = 15 * time.Minute
+	}
+	if tokenConfig.APIKeyPrefix == "" {
+		tokenConfig.APIKeyPrefix = defaultAPIKeyPrefix
+	}
+	for _, policy := range cf.LifetimePolicies {
+		// a zero lifetime would issue tokens that are expired at once
+		if policy.ValidityInMins <= 0 {
+			continue
+		}
+		key := domain.LifetimeKey(policy.Role, policy.UniqueKey)
+		tokenConfig.Lifetimes[key] = time.Duration(policy.ValidityInMins) * time.Minute
+	}
+	for _, limit := range cf.SessionLimits {
+		tokenConfig.SessionLimits[limit.Role] = domain.SessionLimit{
+			Role:   limit.Role,
+			Max:    limit.MaxSessions,
+			Policy: limit.Policy,
+		}
+	}
+	for _, policy := range cf.TokenExchangePolicies {
+		tokenConfig.ExchangeRoles[policy.Role] = append(tokenConfig.ExchangeRoles[policy.Role], policy.ExchangeRoles...)
+		tokenConfig.ExchangeAudiences[policy.Role] = append(tokenConfig.ExchangeAudiences[policy.Role], policy.Audiences...)
+	}
+	for _, limit := range cf.RateLimits {
+		window := time.Duration(limit.WindowInSecs) * time.Second
+		if window == 0 {
+			window = time.Minute
+		}
+		tokenConfig.RateLimits[limit.Scope] = domain.RateLimit{
+			Limit:  limit.Limit,
+			Window: window,
+		}
+	}
+	for _, timeout := range cf.IdleTimeouts {
+		tokenConfig.IdleTimeouts[timeout.Role] = time.Duration(timeout.TimeoutInMins) * time.Minute
+	}
+	var auditSink audit.Sink
+	if cf.AuditSink != nil {
+		auditSink = &requestAuditSink{sink: cf.AuditSink}
+	}
+	recorder := cf.Metrics
+	if recorder == nil {
+		recorder = metrics.NewNopRecorder()
+	}
+	var tracer trace.Tracer
+	if cf.TracerProvider != nil {
+		tracer = cf.TracerProvider.Tracer(tracerName)
+	}
+	if cf.PasswordHasher == nil {
+		cf.PasswordHasher = credentials.NewHasher(credentials.AlgorithmArgon2id)
 	}
 	cl = &authClient{
-		config: cf,
-		ts:     internal.NewTokenService(rs, tokenConfig),
+		config:  cf,
+		ts:      internal.NewTokenService(rs, tokenConfig, auditSink, recorder, tracer),
+		audit:   auditSink,
+		metrics: recorder,
+		tracer:  tracer,
+	}
+	if cl.tracer == nil {
+		cl.tracer = noop.NewTracerProvider().Tracer(tracerName)
 	}
 
-	domain.Logger().Infof("%s: ClientInitialised", domain.LogKeyword)
+	domain.Logger().Info(domain.LogKeyword + ": ClientInitialised")
 }
 
 func GetClient() *authClient {
@@ -56,68 +397,145 @@ func GetClient() *authClient {
 func (cl *authClient) Authenticate(
 	next http.Handler,
 	roles string,
+) http.HandlerFunc {
+	return cl.AuthenticateSubject(next, roles, "")
+}
+
+// AuthenticateSubject is Authenticate restricted to tokens issued to subject.
+func (cl *authClient) AuthenticateSubject(
+	next http.Handler,
+	roles string,
+	subject SubjectType,
 ) http.HandlerFunc {
 	return func(
 		w http.ResponseWriter,
 		r *http.Request,
 	) {
-		ctx := r.Context()
-
-		logger := pkg.GetFromContext(ctx)
-		tv := r.Header.Get("Authorization")
-		at, err := cl.ts.Validate(
-			ctx,
-			tv,
+		ctx, _, err := cl.AuthenticateSubjectContext(
+			r.Context(),
+			GetAccessToken(r.Header.Get("Authorization")),
+			roles,
+			subject,
 		)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			if errors.Is(err, pkg.ErrAuthTokenExpired) || errors.Is(err, pkg.ErrAuthTokenInvalid) || errors.Is(
-				err, pkg.ErrAuthTokenMalformed,
-			) {
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(err.Error())
-				return
-			}
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(err.Error())
-		}
-		roleMap := getAuthorizationRoleMap(roles)
-		_, found := roleMap[at.Role]
-		if !found {
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode("RoleMismatch")
+			writeError(w, err)
 			return
 		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
 
-		ctx = context.WithValue(ctx, AuthIDKey, at.AuthID)
-		r = r.WithContext(ctx)
-
-		ctx = context.WithValue(ctx, AuthRoleKey, at.Role)
-		r = r.WithContext(ctx)
+// AuthenticateContext validates accessToken, a JWT or an API key, checks its role against the dot
+// separated roles and returns ctx populated with the auth ID, role, request
+// header and logger. It is the framework agnostic core of Authenticate.
+func (cl *authClient) AuthenticateContext(
+	ctx context.Context,
+	accessToken string,
+	roles string,
+) (context.Context, *TokenValue, error) {
+	return cl.AuthenticateSubjectContext(ctx, accessToken, roles, "")
+}
 
-		headerDTO := GetHeaderDTO(ctx)
-		headerDTO.AuthID = string(at.AuthID)
+// AuthenticateSubjectContext is AuthenticateContext restricted to tokens issued
+// to subject, an empty subject accepts every token.
+func (cl *authClient) AuthenticateSubjectContext(
+	ctx context.Context,
+	accessToken string,
+	roles string,
+	subject SubjectType,
+) (context.Context, *TokenValue, error) {
+	spanCtx, span := cl.tracer.Start(
+		ctx, "goauth.Authenticate",
+		trace.WithAttributes(attribute.String("goauth.tracking_id", GetHeaderDTO(ctx).TrackingID)),
+	)
+	defer span.End()
 
-		ctx = context.WithValue(ctx, RequestHeaderContextKey, headerDTO)
-		r = r.WithContext(ctx)
+	err := cl.throttle(spanCtx, RateLimitAuthenticate)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
+	}
+	at, scopes, err := cl.validateCredential(
+		spanCtx,
+		accessToken,
+		getSessionActivity(ctx),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
+	}
+	span.SetAttributes(attribute.String("goauth.auth_id", string(at.AuthID)))
+	err = cl.ts.Throttle(spanCtx, RateLimitAuthenticate, "aid:"+string(at.AuthID))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
+	}
+	roleMap := getAuthorizationRoleMap(roles)
+	_, found := roleMap[at.Role]
+	if !found {
+		span.SetStatus(codes.Error, pkg.ErrAuthRoleMismatch.Error())
+		cl.metrics.ValidationFailed(metrics.ReasonRoleMismatch)
+		cl.emitValidationFailed(spanCtx, at, pkg.ErrAuthRoleMismatch)
+		return nil, nil, pkg.ErrAuthRoleMismatch
+	}
+	if subject != "" && at.SubjectType() != subject {
+		span.SetStatus(codes.Error, pkg.ErrAuthSubjectMismatch.Error())
+		cl.metrics.ValidationFailed(metrics.ReasonSubjectMismatch)
+		cl.emitValidationFailed(spanCtx, at, pkg.ErrAuthSubjectMismatch)
+		return nil, nil, pkg.ErrAuthSubjectMismatch
+	}
 
-		logger.WithField("auth_id", headerDTO.AuthID)
-		ctx = context.WithValue(ctx, LoggerContextKey, logger)
-		r = r.WithContext(ctx)
-		next.ServeHTTP(w, r)
+	tokenValue := TokenValue{
+		AuthID:    string(at.AuthID),
+		Role:      at.Role,
+		UniqueKey: at.UniqueKey,
 	}
+	ctx = withAuth(ctx, tokenValue, at.SubjectType())
+	ctx = withActor(ctx, at.Actor)
+	ctx = context.WithValue(ctx, AuthenticationKey, Authentication{
+		Time:    at.AuthenticatedAt(),
+		Methods: at.AuthMethods,
+		Level:   at.AuthLevel,
+	})
+	return withScopes(ctx, scopes), &tokenValue, nil
 }
 
+// CreateToken starts a session for dto. When the auth ID has enrolled TOTP
+// only MFAToken is set, expiring at ExpiresAt, and VerifyMFA creates the
+// session once the second factor is verified.
 func (cl *authClient) CreateToken(
 	ctx context.Context,
 	dto TokenValue,
 ) (*TokenResponseDTO, error) {
 	err := pkg.Validate.Struct(dto)
 	if err != nil {
-		domain.Logger().Infof("%s: CreateToken Validation: %v", domain.LogKeyword, err)
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": CreateToken Validation", "error", err)
 		return nil, pkg.ErrFieldValidation
 	}
-	accessTokenDTO := dto.ToInternalToken()
+	return cl.createSession(ctx, dto.ToInternalToken(), nil)
+}
+
+// createSession creates the session of a user who proved their identity, or
+// holds it back until the second factor when one is enrolled. idToken is only
+// issued by VerifyMFA then, so it never precedes the second factor.
+func (cl *authClient) createSession(
+	ctx context.Context,
+	accessTokenDTO domain.TokenDTO,
+	idToken *domain.IDTokenDTO,
+) (*TokenResponseDTO, error) {
+	mfaToken, pending, err := cl.ts.BeginMFA(ctx, accessTokenDTO, idToken)
+	if err != nil {
+		return nil, err
+	}
+	if mfaToken != "" {
+		return &TokenResponseDTO{
+			MFAToken:  mfaToken,
+			ExpiresAt: pending.ExpiresAt.UnixMilli(),
+		}, nil
+	}
 	tokenResponse, err := cl.ts.Create(
 		ctx, accessTokenDTO,
 	)
@@ -132,6 +550,9 @@ func (cl *authClient) CreateToken(
 	return &res, nil
 }
 
+// RefreshToken rotates the refresh key of accessToken, it fails with
+// pkg.ErrRateLimited once the client IP or refresh key exceeds
+// RateLimitRefresh.
 func (cl *authClient) RefreshToken(
 	ctx context.Context,
 	refreshKey string,
@@ -140,6 +561,10 @@ func (cl *authClient) RefreshToken(
 	if refreshKey == "" || accessToken == "" {
 		return nil, pkg.ErrFieldValidation
 	}
+	err := cl.throttle(ctx, RateLimitRefresh, "rk:"+refreshKey)
+	if err != nil {
+		return nil, err
+	}
 
 	tokenResponse, err := cl.ts.Refresh(
 		ctx, refreshKey, string(accessToken),
@@ -162,8 +587,12 @@ func (cl *authClient) Validate(
 	if accessToken == "" {
 		return nil, pkg.ErrFieldValidation
 	}
+	err := cl.throttle(ctx, RateLimitAuthenticate)
+	if err != nil {
+		return nil, err
+	}
 	tokenDTO, err := cl.ts.Validate(
-		ctx, string(accessToken),
+		ctx, string(accessToken), getSessionActivity(ctx),
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type PrometheusRecorder struct {
+	created          *prometheus.CounterVec
+	refreshed        *prometheus.CounterVec
+	invalidated      prometheus.Counter
+	validationFailed *prometheus.CounterVec
+	repository       *prometheus.HistogramVec
+}
+
+// NewPrometheusRecorder creates the goauth collectors under namespace and
+// registers them on registerer.
+func NewPrometheusRecorder(
+	registerer prometheus.Registerer,
+	namespace string,
+) (*PrometheusRecorder, error) {
+	r := &PrometheusRecorder{
+		created: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "goauth",
+				Name:      "tokens_created_total",
+				Help:      "Number of tokens created.",
+			}, []string{"role"},
+		),
+		refreshed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "goauth",
+				Name:      "tokens_refreshed_total",
+				Help:      "Number of tokens refreshed.",
+			}, []string{"role"},
+		),
+		invalidated: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "goauth",
+				Name:      "tokens_invalidated_total",
+				Help:      "Number of tokens invalidated.",
+			},
+		),
+		validationFailed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: "goauth",
+				Name:      "validation_failures_total",
+				Help:      "Number of rejected access tokens by reason.",
+			}, []string{"reason"},
+		),
+		repository: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Subsystem: "goauth",
+				Name:      "repository_duration_seconds",
+				Help:      "Latency of token repository calls.",
+				Buckets:   prometheus.DefBuckets,
+			}, []string{"operation", "status"},
+		),
+	}
+
+	collectors := []prometheus.Collector{
+		r.created, r.refreshed, r.invalidated, r.validationFailed, r.repository,
+	}
+	for _, collector := range collectors {
+		err := registerer.Register(collector)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *PrometheusRecorder) TokenCreated(
+	role string,
+) {
+	r.created.WithLabelValues(role).Inc()
+}
+
+func (r *PrometheusRecorder) TokenRefreshed(
+	role string,
+) {
+	r.refreshed.WithLabelValues(role).Inc()
+}
+
+func (r *PrometheusRecorder) TokenInvalidated(
+	count int,
+) {
+	r.invalidated.Add(float64(count))
+}
+
+func (r *PrometheusRecorder) ValidationFailed(
+	reason string,
+) {
+	r.validationFailed.WithLabelValues(reason).Inc()
+}
+
+func (r *PrometheusRecorder) ObserveRepository(
+	operation string,
+	duration time.Duration,
+	err error,
+) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	r.repository.WithLabelValues(operation, status).Observe(duration.Seconds())
+}
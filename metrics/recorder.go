@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"time"
+)
+
+// Validation failure reasons reported by Recorder.ValidationFailed.
+const (
+	ReasonExpired         = "expired"
+	ReasonInvalid         = "invalid"
+	ReasonMalformed       = "malformed"
+	ReasonIdle            = "idle"
+	ReasonRoleMismatch    = "role_mismatch"
+	ReasonSubjectMismatch = "subject_mismatch"
+)
+
+// Recorder collects token operation metrics. Operation names passed to
+// ObserveRepository are the IToken method names.
+type Recorder interface {
+	TokenCreated(role string)
+	TokenRefreshed(role string)
+	TokenInvalidated(count int)
+	ValidationFailed(reason string)
+	ObserveRepository(operation string, duration time.Duration, err error)
+}
+
+type nopRecorder struct{}
+
+// NewNopRecorder returns a Recorder that discards every measurement.
+func NewNopRecorder() Recorder {
+	return nopRecorder{}
+}
+
+func (nopRecorder) TokenCreated(string) {}
+
+func (nopRecorder) TokenRefreshed(string) {}
+
+func (nopRecorder) TokenInvalidated(int) {}
+
+func (nopRecorder) ValidationFailed(string) {}
+
+func (nopRecorder) ObserveRepository(string, time.Duration, error) {}
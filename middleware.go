@@ -3,13 +3,17 @@ package goauth
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"runtime/debug"
 	"strings"
 
 	"github.com/google/uuid"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
 )
 
 func recoverHandler(
@@ -21,8 +25,8 @@ func recoverHandler(
 	) {
 		defer func() {
 			if err := recover(); err != nil {
-				logger := domain.Logger()
-				logger.Panic(err)
+				logger := domain.LoggerFromContext(r.Context())
+				logger.Error(domain.LogKeyword+": Panic", "error", err, "stack", string(debug.Stack()))
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusInternalServerError)
 				json.NewEncoder(w).Encode("Unhandled Exception : Panic")
@@ -40,37 +44,39 @@ func loggerMiddleware(
 		w http.ResponseWriter,
 		r *http.Request,
 	) {
-		logger := domain.Logger()
-
-		dto := GetHeaderDTO(r.Context())
-		logFields := logrus.Fields{}
-		logFields["topic"] = topicName
-		logFields["tracking_id"] = dto.TrackingID
-		if dto.RequestTime != "" {
-			logFields["x_request_time"] = dto.RequestTime
-		}
-		if dto.Version != "" {
-			logFields["x_version"] = dto.Version
-		}
-		if dto.DeviceID != "" {
-			logFields["x_device_id"] = dto.DeviceID
-		}
-		if dto.AuthID != "" {
-			logFields["auth_id"] = dto.AuthID
-		}
-
-		logger.WithFields(logFields)
-
-		contextData := context.WithValue(
-			r.Context(),
-			LoggerContextKey,
-			logger,
-		)
-		r = r.WithContext(contextData)
+		r = r.WithContext(WithRequestLogger(r.Context(), topicName))
 		next.ServeHTTP(w, r)
 	}
 }
 
+// WithRequestLogger stores a logger carrying the topic and the request header
+// fields in ctx, see GetLogger.
+func WithRequestLogger(
+	ctx context.Context,
+	topicName string,
+) context.Context {
+	dto := GetHeaderDTO(ctx)
+	logAttrs := []any{
+		"topic", topicName,
+		"tracking_id", dto.TrackingID,
+	}
+	if dto.RequestTime != "" {
+		logAttrs = append(logAttrs, "x_request_time", dto.RequestTime)
+	}
+	if dto.Version != "" {
+		logAttrs = append(logAttrs, "x_version", dto.Version)
+	}
+	if dto.DeviceID != "" {
+		logAttrs = append(logAttrs, "x_device_id", dto.DeviceID)
+	}
+	if dto.AuthID != "" {
+		logAttrs = append(logAttrs, "auth_id", dto.AuthID)
+	}
+
+	logger := domain.Logger().With(logAttrs...)
+	return domain.WithLogger(ctx, logger)
+}
+
 func requestMetaMiddleware(
 	next http.HandlerFunc,
 ) http.HandlerFunc {
@@ -78,34 +84,55 @@ func requestMetaMiddleware(
 		w http.ResponseWriter,
 		r *http.Request,
 	) {
-		dto := RequestHeaderDTO{
-			TrackingID: uuid.New().String(),
-			IPv4:       getIP(r),
-		}
-		if r.Header.Get("X-Tracking-Id") != "" {
-			dto.TrackingID = r.Header.Get("X-Tracking-Id")
-		}
-		if r.Header.Get("X-Request-Time") != "" {
-			dto.RequestTime = r.Header.Get("X-Request-Time")
-		}
-		if r.Header.Get("X-Version") != "" {
-			dto.Version = r.Header.Get("X-Version")
-		}
-		if r.Header.Get("X-Device-Id") != "" {
-			dto.DeviceID = r.Header.Get("X-Device-Id")
-		}
-		if r.Header.Get("X-Auth-Id") != "" {
-			dto.AuthID = r.Header.Get("X-Auth-Id")
-		}
-
-		ctx := context.WithValue(r.Context(), RequestHeaderContextKey, dto)
-		ctx = context.WithValue(ctx, TrackingIDContextKey, dto.TrackingID)
-		r = r.WithContext(ctx)
-
+		r = r.WithContext(WithRequestMeta(r))
 		next.ServeHTTP(w, r)
 	}
 }
 
+// WithRequestMeta returns the request context populated with the
+// RequestHeaderDTO read from the request headers.
+func WithRequestMeta(
+	r *http.Request,
+) context.Context {
+	dto := RequestHeaderDTO{
+		TrackingID: uuid.New().String(),
+		IPv4:       getIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+	// W3C trace context takes precedence so the tracking ID matches the trace ID
+	ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	spanContext := trace.SpanContextFromContext(ctx)
+	if spanContext.HasTraceID() {
+		dto.TrackingID = spanContext.TraceID().String()
+	} else if r.Header.Get("X-Tracking-Id") != "" {
+		dto.TrackingID = r.Header.Get("X-Tracking-Id")
+	}
+	if r.Header.Get("X-Request-Time") != "" {
+		dto.RequestTime = r.Header.Get("X-Request-Time")
+	}
+	if r.Header.Get("X-Version") != "" {
+		dto.Version = r.Header.Get("X-Version")
+	}
+	if r.Header.Get("X-Device-Id") != "" {
+		dto.DeviceID = r.Header.Get("X-Device-Id")
+	}
+	if r.Header.Get("X-Auth-Id") != "" {
+		dto.AuthID = r.Header.Get("X-Auth-Id")
+	}
+
+	return WithRequestHeader(ctx, dto)
+}
+
+// WithRequestHeader stores dto in ctx for transports other than net/http.
+func WithRequestHeader(
+	ctx context.Context,
+	dto RequestHeaderDTO,
+) context.Context {
+	ctx = context.WithValue(ctx, RequestHeaderContextKey, dto)
+	ctx = context.WithValue(ctx, TrackingIDContextKey, dto.TrackingID)
+	return domain.WithTrackingID(ctx, dto.TrackingID)
+}
+
 func AuthenticateMiddleware(
 	next http.HandlerFunc,
 	roles string,
@@ -118,6 +145,59 @@ func AuthenticateMiddleware(
 	return next
 }
 
+// ServiceAuthenticateMiddleware is AuthenticateMiddleware restricted to
+// service account tokens issued by the client_credentials grant.
+func ServiceAuthenticateMiddleware(
+	next http.HandlerFunc,
+	roles string,
+	topicName string,
+) http.HandlerFunc {
+	next = recoverHandler(next)
+	next = cl.AuthenticateSubject(next, roles, SubjectService)
+	next = loggerMiddleware(next, topicName)
+	next = requestMetaMiddleware(next)
+	return next
+}
+
+// RequireScopes rejects API keys missing one of scopes, it must run after
+// the authentication middleware.
+func RequireScopes(
+	next http.HandlerFunc,
+	scopes ...string,
+) http.HandlerFunc {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		if !HasScopes(r.Context(), scopes...) {
+			writeError(w, pkg.ErrAuthScopeMismatch)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireStepUp rejects requests whose user authenticated less strongly or
+// longer ago than stepUp with pkg.ErrStepUpRequired and an RFC 9470 challenge,
+// it must run after the authentication middleware.
+func RequireStepUp(
+	next http.HandlerFunc,
+	stepUp StepUp,
+) http.HandlerFunc {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		err := CheckStepUp(r.Context(), stepUp)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", stepUp.Challenge())
+			writeError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
 func UnauthenticateMiddleware(
 	next http.HandlerFunc,
 	topicName string,
@@ -128,6 +208,43 @@ func UnauthenticateMiddleware(
 	return next
 }
 
+// StatusCode maps the errors returned by AuthenticateContext to an HTTP status.
+// Valid tokens lacking the role, subject or scope of the call are forbidden.
+func StatusCode(
+	err error,
+) int {
+	if errors.Is(err, pkg.ErrAuthRoleMismatch) || errors.Is(err, pkg.ErrAuthSubjectMismatch) || errors.Is(
+		err, pkg.ErrAuthScopeMismatch,
+	) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, pkg.ErrAuthTokenExpired) || errors.Is(err, pkg.ErrAuthTokenInvalid) || errors.Is(
+		err, pkg.ErrAuthTokenMalformed,
+	) || errors.Is(err, pkg.ErrAuthSessionIdle) || errors.Is(err, pkg.ErrClientInvalid) || errors.Is(
+		err, pkg.ErrStepUpRequired,
+	) || errors.Is(err, pkg.ErrTOTPInvalid) || errors.Is(err, pkg.ErrMFATokenInvalid) || errors.Is(
+		err, pkg.ErrOTPInvalid,
+	) || errors.Is(err, pkg.ErrInvalidCredentials) {
+		return http.StatusUnauthorized
+	}
+	if errors.Is(err, pkg.ErrOTPLocked) || errors.Is(err, pkg.ErrTOTPLocked) || errors.Is(
+		err, pkg.ErrRateLimited,
+	) || errors.Is(err, pkg.ErrAccountLocked) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(
+	w http.ResponseWriter,
+	err error,
+) {
+	w.Header().Set("Content-Type", "application/json")
+	SetRetryAfter(w.Header(), err)
+	w.WriteHeader(StatusCode(err))
+	json.NewEncoder(w).Encode(err.Error())
+}
+
 func getAuthorizationRoleMap(
 	roleString string,
 ) map[string]bool {
@@ -0,0 +1,171 @@
+package goauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal"
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// IDTokenValue describes how the user authenticated. ClientID is the aud of
+// the ID token, Claims adds profile claims such as name or email and cannot
+// override the registered ones.
+type IDTokenValue struct {
+	ClientID string                 `json:"client_id" validate:"required,max=100"`
+	Nonce    string                 `json:"nonce,omitempty" validate:"max=255"`
+	AuthTime time.Time              `json:"auth_time,omitempty"`
+	ACR      string                 `json:"acr,omitempty" validate:"max=100"`
+	AMR      []string               `json:"amr,omitempty" validate:"dive,required,max=20"`
+	Claims   map[string]interface{} `json:"claims,omitempty"`
+}
+
+func (e *IDTokenValue) toInternal(
+	authID string,
+	accessToken pkg.JWTToken,
+) domain.IDTokenDTO {
+	return domain.IDTokenDTO{
+		AuthID:      domain.AuthID(authID),
+		Audience:    []string{e.ClientID},
+		Nonce:       e.Nonce,
+		AuthTime:    e.AuthTime,
+		ACR:         e.ACR,
+		AMR:         e.AMR,
+		Profile:     e.Claims,
+		AccessToken: string(accessToken),
+	}
+}
+
+// CreateIDToken mints an OpenID Connect ID token for authID, signed with
+// Config.IDTokenSigningKey.
+func (cl *authClient) CreateIDToken(
+	ctx context.Context,
+	authID string,
+	dto IDTokenValue,
+) (string, error) {
+	err := pkg.Validate.Struct(dto)
+	if err != nil || authID == "" {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": CreateIDToken Validation", "error", err)
+		return "", pkg.ErrFieldValidation
+	}
+	return cl.ts.CreateIDToken(dto.toInternal(authID, ""))
+}
+
+// CreateTokenWithIDToken is CreateToken returning an ID token bound to the
+// access token through at_hash. When only MFAToken is set the ID token is
+// returned by VerifyMFA.
+func (cl *authClient) CreateTokenWithIDToken(
+	ctx context.Context,
+	dto TokenValue,
+	idToken IDTokenValue,
+) (*TokenResponseDTO, error) {
+	err := pkg.Validate.Struct(idToken)
+	if err != nil {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": CreateIDToken Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	err = pkg.Validate.Struct(dto)
+	if err != nil {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": CreateToken Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	if !cl.ts.IDTokenEnabled() {
+		return nil, pkg.ErrIDTokenKeyMissing
+	}
+	idTokenDTO := idToken.toInternal(dto.AuthID, "")
+	res, err := cl.createSession(ctx, dto.ToInternalToken(), &idTokenDTO)
+	if err != nil || res.MFAToken != "" {
+		return res, err
+	}
+	idTokenDTO.AccessToken = string(res.AccessToken)
+	res.IDToken, err = cl.ts.CreateIDToken(idTokenDTO)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// DiscoveryEndpoints are the absolute URLs the application serves the goauth
+// handlers on, empty endpoints are left out of the discovery document.
+type DiscoveryEndpoints struct {
+	AuthorizationEndpoint       string
+	TokenEndpoint               string
+	JWKSURI                     string
+	DeviceAuthorizationEndpoint string
+}
+
+type discoveryDocument struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                     string   `json:"token_endpoint,omitempty"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint,omitempty"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// DiscoveryHandler serves /.well-known/openid-configuration, Config.Issuer
+// must then be the issuer URL.
+func (cl *authClient) DiscoveryHandler(
+	endpoints DiscoveryEndpoints,
+) http.HandlerFunc {
+	document := discoveryDocument{
+		Issuer:                      cl.ts.Issuer(),
+		AuthorizationEndpoint:       endpoints.AuthorizationEndpoint,
+		TokenEndpoint:               endpoints.TokenEndpoint,
+		DeviceAuthorizationEndpoint: endpoints.DeviceAuthorizationEndpoint,
+		JWKSURI:                     endpoints.JWKSURI,
+		ResponseTypesSupported:      []string{},
+		GrantTypesSupported: []string{
+			GrantTypeClientCredentials, GrantTypeAuthorizationCode, GrantTypeDeviceCode, GrantTypeTokenExchange,
+		},
+		// HashSubject gives every client the same sub, which is still public
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{internal.IDTokenAlgorithm},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		ScopesSupported:                   []string{"openid"},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "acr", "amr", "at_hash",
+		},
+	}
+	if endpoints.AuthorizationEndpoint != "" {
+		document.ResponseTypesSupported = append(document.ResponseTypesSupported, "code")
+		document.CodeChallengeMethodsSupported = []string{domain.CodeChallengeMethodS256}
+	}
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		writeJSON(w, "public, max-age=3600", document)
+	}
+}
+
+// JWKSHandler serves the public key of Config.IDTokenSigningKey.
+func (cl *authClient) JWKSHandler() http.HandlerFunc {
+	return func(
+		w http.ResponseWriter,
+		r *http.Request,
+	) {
+		writeJSON(w, "public, max-age=3600", cl.ts.JWKS())
+	}
+}
+
+func writeJSON(
+	w http.ResponseWriter,
+	cacheControl string,
+	body interface{},
+) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(body)
+}
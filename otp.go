@@ -0,0 +1,123 @@
+package goauth
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/otp"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+type OTPValue struct {
+	// Token is the session created once the code is verified
+	Token     TokenValue  `json:"token"`
+	Channel   otp.Channel `json:"channel" validate:"required,oneof=email sms"`
+	Recipient string      `json:"recipient" validate:"required,max=254"`
+	// MagicLinkURL sends this URL with a token query parameter instead of a
+	// numeric code, the page passes the token to VerifyMagicLink
+	MagicLinkURL string `json:"magic_link_url,omitempty" validate:"omitempty,url"`
+}
+
+// OTPChallenge identifies the code sent to the recipient, VerifyOTP needs it
+// along with the code.
+type OTPChallenge struct {
+	ChallengeID string `json:"challenge_id"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+// SendOTP delivers a one-time passcode or magic link through Config.OTPSender.
+// It fails with pkg.ErrOTPLocked while the recipient is locked out and with
+// pkg.ErrRateLimited once the client IP or recipient exceeds RateLimitOTP.
+func (cl *authClient) SendOTP(
+	ctx context.Context,
+	dto OTPValue,
+) (*OTPChallenge, error) {
+	if cl.config.OTPSender == nil {
+		return nil, pkg.ErrOTPSenderMissing
+	}
+	err := pkg.Validate.Struct(dto)
+	if err != nil {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": SendOTP Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	err = cl.throttle(ctx, RateLimitOTP, "rcp:"+dto.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	challengeID, secret, challenge, err := cl.ts.CreateOTP(ctx, domain.OTPDTO{
+		Token:     dto.Token.ToInternalToken(),
+		Channel:   string(dto.Channel),
+		Recipient: dto.Recipient,
+		MagicLink: dto.MagicLinkURL != "",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	message := otp.Message{
+		Channel:   dto.Channel,
+		Recipient: dto.Recipient,
+		ExpiresAt: challenge.ExpiresAt,
+	}
+	if challenge.MagicLink {
+		link, err := url.Parse(dto.MagicLinkURL)
+		if err != nil {
+			return nil, pkg.ErrFieldValidation
+		}
+		query := link.Query()
+		query.Set("token", secret)
+		link.RawQuery = query.Encode()
+		message.Link = link.String()
+	} else {
+		message.Code = secret
+	}
+	err = cl.config.OTPSender.Send(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	return &OTPChallenge{
+		ChallengeID: challengeID,
+		ExpiresAt:   challenge.ExpiresAt.UnixMilli(),
+	}, nil
+}
+
+// VerifyOTP creates the session of the challenge once code matches, like
+// CreateToken it may require the second factor first.
+func (cl *authClient) VerifyOTP(
+	ctx context.Context,
+	challengeID string,
+	code string,
+) (*TokenResponseDTO, error) {
+	if challengeID == "" || code == "" {
+		return nil, pkg.ErrFieldValidation
+	}
+	err := cl.throttle(ctx, RateLimitOTP)
+	if err != nil {
+		return nil, err
+	}
+	token, err := cl.ts.VerifyOTP(ctx, challengeID, code)
+	if err != nil {
+		return nil, err
+	}
+	return cl.createSession(ctx, *token, nil)
+}
+
+// VerifyMagicLink is VerifyOTP for the token query parameter of a magic link.
+func (cl *authClient) VerifyMagicLink(
+	ctx context.Context,
+	linkToken string,
+) (*TokenResponseDTO, error) {
+	if linkToken == "" {
+		return nil, pkg.ErrFieldValidation
+	}
+	err := cl.throttle(ctx, RateLimitOTP)
+	if err != nil {
+		return nil, err
+	}
+	token, err := cl.ts.VerifyMagicLink(ctx, linkToken)
+	if err != nil {
+		return nil, err
+	}
+	return cl.createSession(ctx, *token, nil)
+}
@@ -0,0 +1,106 @@
+package otp
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type Channel string
+
+const (
+	ChannelEmail Channel = "email"
+	ChannelSMS   Channel = "sms"
+)
+
+// Message is a one-time passcode to deliver, Link is set instead of Code for
+// magic links.
+type Message struct {
+	Channel   Channel   `json:"channel"`
+	Recipient string    `json:"recipient"`
+	Code      string    `json:"code,omitempty"`
+	Link      string    `json:"link,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Sender delivers one-time passcodes by email, SMS or any other channel. Send
+// is called synchronously on the request path.
+type Sender interface {
+	Send(
+		ctx context.Context,
+		message Message,
+	) error
+}
+
+type LogSender struct {
+	logger *slog.Logger
+}
+
+// NewLogSender logs every message including its code, for development only.
+func NewLogSender(
+	logger *slog.Logger,
+) *LogSender {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LogSender{
+		logger: logger,
+	}
+}
+
+func (s *LogSender) Send(
+	ctx context.Context,
+	message Message,
+) error {
+	s.logger.InfoContext(
+		ctx, "GoAuth: OTP",
+		"channel", message.Channel,
+		"recipient", message.Recipient,
+		"code", message.Code,
+		"link", message.Link,
+		"expires_at", message.ExpiresAt,
+	)
+	return nil
+}
+
+// MemorySender keeps the sent messages, for tests.
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(
+	_ context.Context,
+	message Message,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Last returns the latest message sent to recipient.
+func (s *MemorySender) Last(
+	recipient string,
+) (Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].Recipient == recipient {
+			return s.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 var (
@@ -10,9 +11,51 @@ var (
 	ErrAuthTokenInvalid      = errors.New("AuthTokenInvalid")
 	ErrAuthTokenMalformed    = errors.New("AuthTokenMalformed")
 	ErrAuthTokenExpired      = errors.New("AuthTokenExpired")
+	ErrAuthSessionIdle       = errors.New("AuthSessionIdle")
+	ErrAuthRoleMismatch      = errors.New("RoleMismatch")
+	ErrAuthSubjectMismatch   = errors.New("SubjectMismatch")
+	ErrAuthScopeMismatch     = errors.New("ScopeMismatch")
+	ErrClientInvalid         = errors.New("ClientInvalid")
+	ErrAPIKeyNotFound        = errors.New("APIKeyNotFound")
+	ErrIDTokenKeyMissing     = errors.New("IDTokenKeyMissing")
+	ErrGrantInvalid          = errors.New("GrantInvalid")
+	ErrAuthorizationPending  = errors.New("AuthorizationPending")
+	ErrSlowDown              = errors.New("SlowDown")
+	ErrAccessDenied          = errors.New("AccessDenied")
+	ErrDeviceCodeExpired     = errors.New("DeviceCodeExpired")
+	ErrTokenExchangeDenied   = errors.New("TokenExchangeDenied")
+	ErrStepUpRequired        = errors.New("StepUpRequired")
+	ErrTOTPInvalid           = errors.New("TOTPInvalid")
+	ErrTOTPLocked            = errors.New("TOTPLocked")
+	ErrTOTPNotEnrolled       = errors.New("TOTPNotEnrolled")
+	ErrTOTPAlreadyEnrolled   = errors.New("TOTPAlreadyEnrolled")
+	ErrMFATokenInvalid       = errors.New("MFATokenInvalid")
+	ErrOTPInvalid            = errors.New("OTPInvalid")
+	ErrOTPLocked             = errors.New("OTPLocked")
+	ErrOTPSenderMissing      = errors.New("OTPSenderMissing")
+	ErrInvalidCredentials    = errors.New("InvalidCredentials")
+	ErrNoCredentialStore     = errors.New("NoCredentialStore")
+	ErrRateLimited           = errors.New("RateLimited")
+	ErrAccountLocked         = errors.New("AccountLocked")
 	ErrAuthRefreshKeyInvalid = errors.New("AuthRefreshKeyInvalid")
+	ErrSessionLimitReached   = errors.New("SessionLimitReached")
 )
 
+// RateLimitError rejects a throttled or locked out call, errors.Is matches
+// Err. RetryAfter is the wait until the call may succeed again.
+type RateLimitError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
 type JWTToken string
 
 func MapToString(
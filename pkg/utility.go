@@ -2,12 +2,11 @@ package pkg
 
 import (
 	"context"
+	"log/slog"
 	"regexp"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/sirupsen/logrus"
 
-	"github.com/c0dev0yager/goauth"
 	"github.com/c0dev0yager/goauth/internal/domain"
 )
 
@@ -33,16 +32,10 @@ func validateOnlyDashAndUnderscore(fl validator.FieldLevel) bool {
 	return re.MatchString(value)
 }
 
+// GetFromContext returns the logger enriched with the request fields by the
+// goauth middlewares, or the package logger outside of a request.
 func GetFromContext(
 	ctx context.Context,
-) *logrus.Logger {
-	logger, ok := ctx.Value(goauth.LoggerContextKey).(logrus.Logger)
-	if ok {
-		logger.WithField("event", "message")
-		return &logger
-	}
-
-	newLogger := domain.Logger()
-	newLogger.WithField("event", "message")
-	return newLogger
+) *slog.Logger {
+	return domain.LoggerFromContext(ctx)
 }
@@ -0,0 +1,73 @@
+package goauth
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+type RateLimitScope = domain.RateLimitScope
+
+const (
+	// RateLimitLogin throttles Login and VerifyMFA
+	RateLimitLogin = domain.RateLimitLogin
+	// RateLimitRefresh throttles RefreshToken
+	RateLimitRefresh = domain.RateLimitRefresh
+	// RateLimitOTP throttles SendOTP, VerifyOTP and VerifyMagicLink
+	RateLimitOTP = domain.RateLimitOTP
+	// RateLimitAuthenticate throttles Validate and the authentication
+	// middlewares
+	RateLimitAuthenticate = domain.RateLimitAuthenticate
+)
+
+// RateLimit allows Limit calls of Scope within any sliding window of
+// WindowInSecs, which defaults to a minute. The limit applies to each key
+// separately, the client IP and the login, recipient, refresh key or auth ID.
+type RateLimit struct {
+	Scope        RateLimitScope
+	Limit        int
+	WindowInSecs int
+}
+
+// throttle applies the rate limit of scope to the client IP of ctx and keys.
+func (cl *authClient) throttle(
+	ctx context.Context,
+	scope RateLimitScope,
+	keys ...string,
+) error {
+	if ip := GetHeaderDTO(ctx).IPv4; ip != "" {
+		keys = append([]string{"ip:" + ip}, keys...)
+	}
+	return cl.ts.Throttle(ctx, scope, keys...)
+}
+
+// RetryAfter returns the wait before retrying a call rejected by a rate limit
+// or lockout, zero for other errors.
+func RetryAfter(
+	err error,
+) time.Duration {
+	var rateLimitErr *pkg.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return rateLimitErr.RetryAfter
+	}
+	return 0
+}
+
+// SetRetryAfter sets the Retry-After header in whole seconds when err is a
+// rate limit or lockout, before the status is written.
+func SetRetryAfter(
+	header http.Header,
+	err error,
+) {
+	retryAfter := RetryAfter(err)
+	if retryAfter <= 0 {
+		return
+	}
+	header.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+}
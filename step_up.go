@@ -0,0 +1,110 @@
+package goauth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// Authentication levels of TokenValue.AuthLevel, applications may use higher
+// levels for stronger factors.
+const (
+	AuthLevelSingleFactor = domain.AuthLevelSingleFactor
+	AuthLevelMultiFactor  = domain.AuthLevelMultiFactor
+)
+
+// Authentication methods recorded by the package, see TokenValue.AuthMethods.
+const (
+	AuthMethodPassword     = domain.AuthMethodPassword
+	AuthMethodOTP          = domain.AuthMethodOTP
+	AuthMethodRecoveryCode = domain.AuthMethodRecoveryCode
+)
+
+// Authentication describes how and when the user of the request authenticated.
+type Authentication struct {
+	Time    time.Time
+	Methods []string
+	Level   int
+}
+
+// StepUp is the authentication a request requires, zero fields are not checked.
+// Refreshed tokens keep the level and time of their last authentication, so a
+// level raised by ElevateSession lasts as long as the session. Pair MinLevel
+// with MaxAge to require a recent elevation.
+type StepUp struct {
+	MinLevel int
+	MaxAge   time.Duration
+}
+
+// Challenge is the RFC 9470 WWW-Authenticate value asking the client to step up.
+func (s StepUp) Challenge() string {
+	challenge := `Bearer error="insufficient_user_authentication"`
+	if s.MinLevel > 0 {
+		challenge += fmt.Sprintf(`, acr_values="%d"`, s.MinLevel)
+	}
+	if s.MaxAge > 0 {
+		challenge += fmt.Sprintf(`, max_age="%d"`, int64(s.MaxAge.Seconds()))
+	}
+	return challenge
+}
+
+type ElevationValue struct {
+	// Method is the RFC 8176 method of the second factor, such as "otp"
+	Method string `json:"method" validate:"required,max=20"`
+	Level  int    `json:"level" validate:"min=0"`
+}
+
+// GetAuthentication returns how the user of an authenticated request
+// authenticated, API keys have no authentication time.
+func GetAuthentication(
+	ctx context.Context,
+) Authentication {
+	authentication, _ := ctx.Value(AuthenticationKey).(Authentication)
+	return authentication
+}
+
+// CheckStepUp returns pkg.ErrStepUpRequired unless the user of ctx
+// authenticated as strongly and recently as stepUp requires.
+func CheckStepUp(
+	ctx context.Context,
+	stepUp StepUp,
+) error {
+	authentication := GetAuthentication(ctx)
+	if authentication.Level < stepUp.MinLevel {
+		return pkg.ErrStepUpRequired
+	}
+	if stepUp.MaxAge > 0 && time.Since(authentication.Time) > stepUp.MaxAge {
+		return pkg.ErrStepUpRequired
+	}
+	return nil
+}
+
+// ElevateSession records a second factor the user of accessToken just passed
+// and returns the access token replacing it, the refresh key is unchanged. The
+// elevation is kept by refreshes, see StepUp.
+func (cl *authClient) ElevateSession(
+	ctx context.Context,
+	accessToken pkg.JWTToken,
+	dto ElevationValue,
+) (*TokenResponseDTO, error) {
+	err := pkg.Validate.Struct(dto)
+	if err != nil || accessToken == "" {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": ElevateSession Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	tokenResponse, err := cl.ts.Elevate(
+		ctx, string(accessToken), dto.Method, dto.Level, getSessionActivity(ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	res := TokenResponseDTO{
+		AccessToken: pkg.JWTToken(tokenResponse.AccessToken),
+		RefreshKey:  tokenResponse.RefreshKey,
+		ExpiresAt:   tokenResponse.ExpiresAt,
+	}
+	return &res, nil
+}
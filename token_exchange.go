@@ -0,0 +1,144 @@
+package goauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// TokenTypeAccessToken is the only token type accepted and issued by the
+// token exchange grant.
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+// Actor is the party acting on behalf of the subject of a delegated token, Act
+// is the actor of the token it was exchanged from.
+type Actor struct {
+	AuthID  string
+	Role    string
+	Subject SubjectType
+	Act     *Actor
+}
+
+func toActor(
+	dto *domain.ActorDTO,
+) *Actor {
+	if dto == nil {
+		return nil
+	}
+	return &Actor{
+		AuthID:  string(dto.AuthID),
+		Role:    dto.Role,
+		Subject: dto.Subject,
+		Act:     toActor(dto.Act),
+	}
+}
+
+type TokenExchangeValue struct {
+	SubjectToken pkg.JWTToken `json:"subject_token" validate:"required"`
+	// ActorToken delegates the new token to its bearer, see Config.ActorRoles
+	ActorToken pkg.JWTToken `json:"actor_token,omitempty"`
+	// Role defaults to the role of the subject token, see Config.TokenExchangePolicies
+	Role string `json:"role,omitempty" validate:"max=20,special_character_validation"`
+	// Scopes must be granted to the subject token, nil keeps its scopes
+	Scopes []string `json:"scopes,omitempty" validate:"dive,required,max=100"`
+	// Audience must be that of the subject token or listed in the
+	// TokenExchangePolicy of its role, nil keeps its audience
+	Audience []string `json:"audience,omitempty" validate:"dive,required,max=100"`
+	// ValidityInMins shortens Config.ExchangedTokenValidityInMins
+	ValidityInMins int `json:"validity_in_mins,omitempty" validate:"min=0"`
+}
+
+// ExchangeToken issues a short-lived token for the subject of SubjectToken
+// (RFC 8693), with the actor of ActorToken when impersonating. Exchanged
+// tokens have no refresh key.
+func (cl *authClient) ExchangeToken(
+	ctx context.Context,
+	dto TokenExchangeValue,
+) (*TokenResponseDTO, error) {
+	err := pkg.Validate.Struct(dto)
+	if err != nil {
+		pkg.GetFromContext(ctx).Info(domain.LogKeyword+": ExchangeToken Validation", "error", err)
+		return nil, pkg.ErrFieldValidation
+	}
+	tokenResponse, _, err := cl.ts.ExchangeToken(
+		ctx,
+		string(dto.SubjectToken),
+		string(dto.ActorToken),
+		domain.TokenExchangeDTO{
+			Role:     dto.Role,
+			Scopes:   dto.Scopes,
+			Audience: dto.Audience,
+			Lifetime: time.Duration(dto.ValidityInMins) * time.Minute,
+		},
+		getSessionActivity(ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	res := TokenResponseDTO{
+		AccessToken: pkg.JWTToken(tokenResponse.AccessToken),
+		ExpiresAt:   tokenResponse.ExpiresAt,
+	}
+	return &res, nil
+}
+
+// tokenExchangeGrant serves RFC 8693 requests for access tokens, the non
+// standard role parameter selects the role of the issued token.
+func (cl *authClient) tokenExchangeGrant(
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+	client := cl.grantClient(w, r, GrantTypeTokenExchange)
+	if client == nil {
+		return
+	}
+	form := r.PostForm
+	if form.Get("subject_token") == "" || form.Get("subject_token_type") != TokenTypeAccessToken {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "subject_token must be an access token")
+		return
+	}
+	if form.Get("actor_token") != "" && form.Get("actor_token_type") != TokenTypeAccessToken {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "actor_token must be an access token")
+		return
+	}
+	requested := form.Get("requested_token_type")
+	if requested != "" && requested != TokenTypeAccessToken {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "unsupported requested_token_type")
+		return
+	}
+	dto := TokenExchangeValue{
+		SubjectToken: pkg.JWTToken(form.Get("subject_token")),
+		ActorToken:   pkg.JWTToken(form.Get("actor_token")),
+		Role:         form.Get("role"),
+		Audience:     form["audience"],
+	}
+	if scope := form.Get("scope"); scope != "" {
+		dto.Scopes = strings.Fields(scope)
+	}
+
+	res, err := cl.ExchangeToken(ctx, dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, pkg.ErrAuthScopeMismatch):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "")
+		case errors.Is(err, pkg.ErrTokenExchangeDenied):
+			writeOAuthError(w, http.StatusBadRequest, "invalid_target", "exchange is not allowed")
+		case StatusCode(err) == http.StatusUnauthorized || errors.Is(err, pkg.ErrFieldValidation):
+			// invalid subject or actor tokens, RFC 8693 section 2.2.2
+			writeOAuthError(w, http.StatusBadRequest, "invalid_request", "invalid subject_token or actor_token")
+		default:
+			pkg.GetFromContext(ctx).Error(domain.LogKeyword+": TokenExchangeGrant", "error", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+	response := newOAuthTokenResponse(res, strings.Join(dto.Scopes, " "))
+	response.IssuedTokenType = TokenTypeAccessToken
+	writeJSON(w, "no-store", response)
+}
@@ -0,0 +1,113 @@
+package goauth
+
+import (
+	"context"
+
+	"github.com/c0dev0yager/goauth/internal/domain"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// TOTPEnrollment is shown to the user once, usually URI as a QR code with
+// Secret for manual entry.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// EnrollTOTP starts the TOTP enrollment of authID, accountName labels the
+// account in authenticator apps. The factor is enforced once ConfirmTOTP
+// succeeds.
+func (cl *authClient) EnrollTOTP(
+	ctx context.Context,
+	authID string,
+	accountName string,
+) (*TOTPEnrollment, error) {
+	if authID == "" || accountName == "" {
+		return nil, pkg.ErrFieldValidation
+	}
+	secret, uri, err := cl.ts.EnrollTOTP(ctx, domain.AuthID(authID), accountName)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{
+		Secret: secret,
+		URI:    uri,
+	}, nil
+}
+
+// ConfirmTOTP enables the enrolled factor with a first code and returns the
+// recovery codes, which are not retrievable later.
+func (cl *authClient) ConfirmTOTP(
+	ctx context.Context,
+	authID string,
+	code string,
+) ([]string, error) {
+	if authID == "" || code == "" {
+		return nil, pkg.ErrFieldValidation
+	}
+	return cl.ts.ConfirmTOTP(ctx, domain.AuthID(authID), code)
+}
+
+// VerifyTOTP checks a code of authID, for example before ElevateSession.
+// Codes are rejected with pkg.ErrTOTPInvalid once used, and with
+// pkg.ErrTOTPLocked while too many wrong codes lock authID out.
+func (cl *authClient) VerifyTOTP(
+	ctx context.Context,
+	authID string,
+	code string,
+) error {
+	if authID == "" || code == "" {
+		return pkg.ErrFieldValidation
+	}
+	return cl.ts.VerifyTOTP(ctx, domain.AuthID(authID), code)
+}
+
+func (cl *authClient) DisableTOTP(
+	ctx context.Context,
+	authID string,
+) error {
+	if authID == "" {
+		return pkg.ErrFieldValidation
+	}
+	return cl.ts.DisableTOTP(ctx, domain.AuthID(authID))
+}
+
+// RegenerateRecoveryCodes invalidates the recovery codes of authID and
+// returns new ones.
+func (cl *authClient) RegenerateRecoveryCodes(
+	ctx context.Context,
+	authID string,
+) ([]string, error) {
+	if authID == "" {
+		return nil, pkg.ErrFieldValidation
+	}
+	return cl.ts.RegenerateRecoveryCodes(ctx, domain.AuthID(authID))
+}
+
+// VerifyMFA completes a login held back by CreateToken with a TOTP or recovery
+// code. The session records the method used and AuthLevelMultiFactor, the ID
+// token of CreateTokenWithIDToken is returned along with it.
+func (cl *authClient) VerifyMFA(
+	ctx context.Context,
+	mfaToken string,
+	code string,
+) (*TokenResponseDTO, error) {
+	if mfaToken == "" || code == "" {
+		return nil, pkg.ErrFieldValidation
+	}
+	err := cl.throttle(ctx, RateLimitLogin)
+	if err != nil {
+		return nil, err
+	}
+	tokenResponse, idToken, err := cl.ts.CompleteMFA(ctx, mfaToken, code)
+	if err != nil {
+		return nil, err
+	}
+	res := TokenResponseDTO{
+		AccessToken: pkg.JWTToken(tokenResponse.AccessToken),
+		RefreshKey:  tokenResponse.RefreshKey,
+		ExpiresAt:   tokenResponse.ExpiresAt,
+		IDToken:     idToken,
+	}
+	return &res, nil
+}
@@ -0,0 +1,140 @@
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/c0dev0yager/goauth"
+	"github.com/c0dev0yager/goauth/pkg"
+)
+
+// Refresher exchanges a refresh key and the last access token for a new
+// token, it is implemented by the goauth client.
+type Refresher interface {
+	RefreshToken(
+		ctx context.Context,
+		refreshKey string,
+		accessToken pkg.JWTToken,
+	) (*goauth.TokenResponseDTO, error)
+}
+
+// Transport attaches the bearer token to outgoing requests, refreshes it
+// refreshBefore its expiry and retries a request once after a 401. Concurrent
+// refreshes are collapsed into a single RefreshToken call.
+type Transport struct {
+	base          http.RoundTripper
+	refresher     Refresher
+	refreshBefore time.Duration
+
+	mu    sync.RWMutex
+	token goauth.TokenResponseDTO
+	group singleflight.Group
+}
+
+// NewTransport wraps base, http.DefaultTransport when nil, starting from token.
+func NewTransport(
+	base http.RoundTripper,
+	refresher Refresher,
+	token goauth.TokenResponseDTO,
+	refreshBefore time.Duration,
+) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{
+		base:          base,
+		refresher:     refresher,
+		refreshBefore: refreshBefore,
+		token:         token,
+	}
+}
+
+// Token returns the token currently attached to requests.
+func (t *Transport) Token() goauth.TokenResponseDTO {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.token
+}
+
+func (t *Transport) RoundTrip(
+	req *http.Request,
+) (*http.Response, error) {
+	ctx := req.Context()
+	token := t.Token()
+	if time.Until(time.UnixMilli(token.ExpiresAt)) < t.refreshBefore {
+		refreshed, err := t.refresh(ctx, token.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		token = refreshed
+	}
+
+	resp, err := t.base.RoundTrip(withToken(req, token.AccessToken))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// the body was consumed by the first attempt and cannot be replayed
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	refreshed, err := t.refresh(ctx, token.AccessToken)
+	if err != nil {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	retry := withToken(req, refreshed.AccessToken)
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(retry)
+}
+
+// refresh renews the token unless another request already replaced stale.
+func (t *Transport) refresh(
+	ctx context.Context,
+	stale pkg.JWTToken,
+) (goauth.TokenResponseDTO, error) {
+	val, err, _ := t.group.Do(
+		"refresh", func() (interface{}, error) {
+			current := t.Token()
+			if current.AccessToken != stale {
+				return current, nil
+			}
+			// detached so one cancelled caller does not fail the others
+			refreshed, err := t.refresher.RefreshToken(
+				context.WithoutCancel(ctx), current.RefreshKey, current.AccessToken,
+			)
+			if err != nil {
+				return nil, err
+			}
+			t.mu.Lock()
+			t.token = *refreshed
+			t.mu.Unlock()
+			return *refreshed, nil
+		},
+	)
+	if err != nil {
+		return goauth.TokenResponseDTO{}, err
+	}
+	return val.(goauth.TokenResponseDTO), nil
+}
+
+func withToken(
+	req *http.Request,
+	accessToken pkg.JWTToken,
+) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", "Bearer "+string(accessToken))
+	return clone
+}